@@ -0,0 +1,180 @@
+package book
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Words that number the volumes of a work, left out of a title made
+// from those of the volumes
+var volumeWords = []string{
+	"del", "delen", "band", "bandet", "bind", "bindet", "bok", "boken", "bog", "bogen",
+	"volume", "vol", "part", "book", "teil", "osa", "nide",
+}
+
+// Assemble makes one book of the volumes, in order, like the parts of
+// a trilogy that Runeberg has under several titlekeys. Each volume
+// gets an entry at the top level of the table of contents, with its
+// chapters under it. The metadata of the volumes is merged, and a
+// title page listing the volumes is put first. An empty title means
+// the common start of the titles of the volumes. The chapters of the
+// volumes are taken over, so the volumes are only good for their
+// metadata afterwards. As the page numbers of the volumes repeat, the
+// page breaks get the number of the volume, like "II:123".
+func (c *Converter) Assemble(vols []*Book, title, titleKey string) (*Book, error) {
+	if len(vols) == 0 {
+		return nil, fmt.Errorf("no volumes to assemble")
+	}
+
+	b := &Book{
+		Title:    title,
+		TitleKey: titleKey,
+		Language: vols[0].Language,
+		Images:   map[string][]byte{},
+		Volumes:  vols,
+	}
+	if b.Title == "" {
+		b.Title = commonTitle(vols)
+	}
+
+	var authors, years []string
+	for _, vol := range vols {
+		if vol.Author != "" && !slices.Contains(authors, vol.Author) {
+			authors = append(authors, vol.Author)
+		}
+		if vol.Year != "" {
+			years = append(years, vol.Year)
+		}
+		for _, ct := range vol.Contributors {
+			if !slices.ContainsFunc(b.Contributors, func(o Contributor) bool {
+				return o.Key == ct.Key && o.Role == ct.Role
+			}) {
+				b.Contributors = append(b.Contributors, ct)
+			}
+		}
+	}
+	b.Author = strings.Join(authors, ", ")
+	if len(years) > 0 {
+		b.Year = years[0]
+		if last := years[len(years)-1]; last != b.Year {
+			b.Year += "–" + last
+		}
+	}
+
+	b.Chapters = append(b.Chapters, c.volumesTitlePage(b))
+	for n, vol := range vols {
+		// Images of different volumes may have the same path
+		resolve := func(img Image) []Inline {
+			if _, ok := vol.Images[img.Src]; ok {
+				img.Src = vol.TitleKey + "/" + img.Src
+			}
+			return []Inline{img}
+		}
+		for p, data := range vol.Images {
+			b.Images[vol.TitleKey+"/"+p] = data
+		}
+		for _, src := range vol.MissingImages {
+			b.MissingImages = append(b.MissingImages, vol.TitleKey+": "+src)
+		}
+
+		relabel := func(pb PageBreak) PageBreak {
+			pb.Label = romanNumeral(n+1) + ":" + pb.Label
+			return pb
+		}
+
+		for i, ch := range vol.Chapters {
+			ch.Blocks = mapImages(ch.Blocks, resolve)
+			ch.Blocks = mapPageBreaks(ch.Blocks, relabel)
+			if i == 0 {
+				// The title page of the volume stands for it
+				ch.Title = vol.Title
+				ch.Level = 0
+			} else {
+				ch.Level++
+			}
+			b.Chapters = append(b.Chapters, ch)
+		}
+	}
+
+	return b, nil
+}
+
+// volumesTitlePage returns the title page of an assembled book, with
+// its title, author and volumes.
+func (c *Converter) volumesTitlePage(b *Book) Chapter {
+	lang := c.lang(b)
+	ch := Chapter{
+		Title: msg(lang, "titlepage"),
+		Blocks: []Block{
+			Heading{Level: 1, Content: []Inline{Text(b.Title)}},
+		},
+	}
+	if b.Author != "" {
+		ch.Blocks = append(ch.Blocks, Paragraph{Class: "center", Content: []Inline{Text(b.Author)}})
+	}
+
+	ch.Blocks = append(ch.Blocks, Heading{Level: 2, Content: []Inline{Text(msg(lang, "volumes"))}})
+	for _, vol := range b.Volumes {
+		content := []Inline{Text(vol.Title)}
+		if vol.Year != "" {
+			content = append(content, Text(" ("+vol.Year+")"))
+		}
+		ch.Blocks = append(ch.Blocks, Paragraph{Content: content})
+	}
+
+	ch.Blocks = append(ch.Blocks, Rule{}, SourceNote{URLs: b.sources()})
+
+	return ch
+}
+
+// romanNumeral returns n, from 1 up, in upper-case Roman numerals.
+func romanNumeral(n int) string {
+	var sb strings.Builder
+	for _, d := range []struct {
+		value int
+		s     string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	} {
+		for ; n >= d.value; n -= d.value {
+			sb.WriteString(d.s)
+		}
+	}
+	return sb.String()
+}
+
+// commonTitle returns the words that the titles of the volumes begin
+// with, leaving out a trailing volume word like "del". Or the title of
+// the first volume, if they have none in common.
+func commonTitle(vols []*Book) string {
+	trim := func(w string) string {
+		return strings.TrimRightFunc(w, func(r rune) bool { return unicode.IsPunct(r) })
+	}
+
+	common := strings.Fields(vols[0].Title)
+	for _, vol := range vols[1:] {
+		words := strings.Fields(vol.Title)
+		n := 0
+		for n < len(common) && n < len(words) && trim(common[n]) == trim(words[n]) {
+			n++
+		}
+		common = common[:n]
+	}
+
+	for len(common) > 0 {
+		last := trim(common[len(common)-1])
+		if last != "" && !slices.Contains(volumeWords, strings.ToLower(last)) {
+			common[len(common)-1] = last
+			break
+		}
+		common = common[:len(common)-1]
+	}
+
+	if len(common) == 0 {
+		return vols[0].Title
+	}
+	return strings.Join(common, " ")
+}
@@ -0,0 +1,57 @@
+package book
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Heuristics for books whose page files lack the blank first line
+// (BFL) that marks a page beginning with a new paragraph.
+
+// Default of Options.RepairBFLMinCandidates. If about one in five of
+// the candidates (see startsParagraph) really begins a paragraph, a
+// book with this many is unlikely to have none that should have had a
+// BFL.
+const defaultRepairBFLMinCandidates = 10
+
+// missingBFLCandidates counts the pages of a book without any BFL that
+// might begin a new paragraph, so might have lost their BFL.
+func missingBFLCandidates(texts [][]string) int {
+	var candidates int
+	for _, pages := range texts {
+		for i := 1; i < len(pages); i++ {
+			if ok, _ := startsParagraph(pages[i-1], pages[i]); ok {
+				candidates++
+			}
+		}
+	}
+	return candidates
+}
+
+// startsParagraph tells whether page probably begins a new paragraph:
+// the previous page ends a sentence, and the page does not begin with
+// a lowercase letter. The reason is returned for reporting.
+func startsParagraph(prev, page string) (bool, string) {
+	prev = strings.TrimRight(prev, " \t\n")
+	if prev == "" {
+		return false, ""
+	}
+	page = strings.TrimLeft(page, " \t")
+	if page == "" || page[0] == '\n' {
+		return false, ""
+	}
+
+	last := []rune(prev)[len([]rune(prev))-1]
+	if !strings.ContainsRune(`.!?:»"”`, last) {
+		return false, ""
+	}
+
+	first := []rune(page)[0]
+	if unicode.IsLower(first) || unicode.IsDigit(first) {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("inserted paragraph break, previous page ends with %q and this begins with %q",
+		last, first)
+}
@@ -0,0 +1,605 @@
+// Package book converts books from Project Runeberg
+// (https://runeberg.org) to EPUB.
+package book
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/quite/runepub/internal/process"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// TODO Handles both Metadata+Articles.lst with html-files (drglas)
+// and with Pages/txt-files (korkarlen, dubbelmord). Well, only those
+// specific books have been tried so far.
+//
+// Things are very messy, especially dealing with runebergs htmlish
+// and lack of closing tags.
+
+type Book struct {
+	Title        string
+	TitleKey     string
+	Author       string
+	Language     string
+	URL          string
+	Chapters     Chapters
+	Year         string
+	Work         *Title // Entry in t.lst, nil if not listed there
+	Contributors []Contributor
+	// Contributors whose keys (in Metadata or t.lst) are missing in
+	// a.lst, with the key as name. They are left out of Contributors,
+	// but block CheckPublicDomain.
+	UnknownContributors []Contributor
+	MaybeMissingBFL     bool
+	// Pages that look like they begin a new paragraph but lack a
+	// blank first line, see Options.RepairBFL. Set if MaybeMissingBFL.
+	MissingBFLCandidates int
+	Repairs              []Repair // Changes made to fix the formatting
+	// Words split at line ends that could have been rejoined either
+	// with or without the hyphen
+	Hyphenations []Hyphenation
+	// Images by path in the book, like img/cover.png, which Image
+	// inlines refer to
+	Images map[string][]byte
+	// Sources of images that could not be found
+	MissingImages []string
+	// The books that this one was assembled from, see
+	// Converter.Assemble
+	Volumes []*Book
+	// Title of the collection that the book is a part of, and its
+	// number in it, see Book.Split
+	Collection      string
+	CollectionIndex int
+}
+
+// Hyphenation is a process.Hyphenation that could go either way, with
+// the title of the chapter it is in, for checking by hand.
+type Hyphenation struct {
+	Chapter string
+	Split   string
+	Result  string
+	Reason  string
+}
+
+// Repair is a change made to fix broken formatting of the book.
+type Repair struct {
+	Page   string // Like 0005 for Pages/0005.txt
+	Reason string
+}
+
+type Chapters []Chapter
+
+func (chs Chapters) Titles() []string {
+	var titles []string
+	for _, ch := range chs {
+		titles = append(titles, ch.Title)
+	}
+	return titles
+}
+
+type Chapter struct {
+	Title string
+	// Depth in the table of contents, 0 for the top level. A chapter
+	// goes under the closest one before it with a lower level.
+	Level  int
+	Blocks []Block
+	pages  []string
+}
+
+// New reads a book from a Runeberg zip-file using the default
+// options, see Converter.
+func New(zipData []byte) (*Book, error) {
+	return NewConverter(Options{}).Convert(context.Background(), zipData)
+}
+
+// NewFromFS reads a book from the files of a Runeberg zip-file using
+// the default options, see Converter.
+func NewFromFS(fsys fs.FS) (*Book, error) {
+	return NewConverter(Options{}).ConvertFS(context.Background(), fsys)
+}
+
+func (b *Book) getFrontmatter(fs fs.FS, lang string, quirks Quirks) error {
+	f, err := fs.Open("index.html")
+	if err != nil {
+		return fmt.Errorf("Open failed: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("ReadAll failed: %w", err)
+	}
+
+	ch := Chapter{Title: msg(lang, "titlepage")}
+	body, err := quirks.substitute(string(data))
+	if err != nil {
+		return err
+	}
+	body, err = process.RunebergHtml(body)
+	if err != nil {
+		return err
+	}
+	if ch.Blocks, err = parseBlocks(body); err != nil {
+		return err
+	}
+	ch.Blocks = append(ch.Blocks, Rule{}, SourceNote{URLs: []string{b.URL}})
+
+	b.Chapters = append(b.Chapters, ch)
+
+	return nil
+}
+
+func (b *Book) getMetadata(fs fs.FS) error {
+	f, err := fs.Open("Metadata")
+	if err != nil {
+		return fmt.Errorf("Open failed: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := decodeISO8859_1(scanner.Bytes())
+		k, v, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		v = strings.Trim(v, " \t")
+		switch k {
+		case "TITLE":
+			b.Title = v
+		case "TITLEKEY":
+			b.TitleKey = v
+			b.URL = fmt.Sprintf("https://runeberg.org/%s/", b.TitleKey)
+			if title, ok := titles[b.TitleKey]; ok {
+				b.Work = &title
+				if title.Year != 0 {
+					b.Year = strconv.Itoa(title.Year)
+				}
+			}
+		case "AUTHORKEY":
+			for _, key := range strings.Fields(v) {
+				if err := b.addContributor(key, RoleAuthor); err != nil {
+					return &MetadataError{Field: "AUTHORKEY", Value: key, Msg: "unknown author key"}
+				}
+			}
+		case "COAUTHORKEY", "TRANSLATORKEY":
+			// Not worth failing over, see UnknownContributors
+			role := RoleCoAuthor
+			if k == "TRANSLATORKEY" {
+				role = RoleTranslator
+			}
+			for _, key := range strings.Fields(v) {
+				if err := b.addContributor(key, role); err != nil {
+					b.addUnknownContributor(key, role)
+				}
+			}
+		case "LANGUAGE":
+			b.Language = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Scan failed: %w", err)
+	}
+
+	// t.lst may know of more people than the Metadata. Keys missing
+	// in a.lst are only noted, the lists are not always in sync.
+	if b.Work != nil {
+		for _, role := range roles {
+			for _, key := range b.Work.Keys(role) {
+				if err := b.addContributor(key, role); err != nil {
+					b.addUnknownContributor(key, role)
+				}
+			}
+		}
+	}
+
+	for _, c := range b.Contributors {
+		if c.Role == RoleAuthor {
+			b.Author = c.Name
+			break
+		}
+	}
+
+	fields := []string{"Title", "TitleKey", "Author", "Language"}
+	for _, f := range fields {
+		if b.getStringField(f) == "" {
+			return &MetadataError{Field: f, Msg: "not found"}
+		}
+	}
+
+	return nil
+}
+
+// addContributor adds the person with the key in a.lst, unless
+// already credited in that role.
+func (b *Book) addContributor(key string, role Role) error {
+	var ok bool
+	if b.Contributors, ok = appendContributor(b.Contributors, key, role); !ok {
+		return fmt.Errorf("unknown author key: %s", key)
+	}
+	return nil
+}
+
+// addUnknownContributor notes a key missing in a.lst.
+func (b *Book) addUnknownContributor(key string, role Role) {
+	c := Contributor{Key: key, Name: key, Role: role}
+	if !slices.Contains(b.UnknownContributors, c) {
+		b.UnknownContributors = append(b.UnknownContributors, c)
+	}
+}
+
+func (b *Book) getStringField(field string) string {
+	v := reflect.ValueOf(b)
+	f := reflect.Indirect(v).FieldByName(field)
+	return string(f.String())
+}
+
+func decodeISO8859_1(in []byte) string {
+	decoder := charmap.ISO8859_1.NewDecoder()
+	out, err := decoder.Bytes(in)
+	if err != nil {
+		log.Panic(err)
+	}
+	return string(out)
+}
+
+func (b *Book) getChapters(ctx context.Context, fs fs.FS, quirks Quirks, opts Options) error {
+	// TODO Is Articles.lst in ISO-8859-1?
+	f, err := fs.Open("Articles.lst")
+	if err != nil {
+		return fmt.Errorf("Open failed: %w", err)
+	}
+	defer f.Close()
+
+	var chs []Chapter
+	var titles []string // As in Articles.lst
+	var indents []int
+
+	seqSingleRE := regexp.MustCompile(`^[0-9]{4}$`)
+	seqRangeRE := regexp.MustCompile(`^[0-9]{4}-[0-9]{4}$`)
+	// Expecting lines in Articles.lst like: |titeln|0005-0013
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "index|") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) != 3 {
+			return fmt.Errorf("Line does not have 3 fields: %q", line)
+		}
+
+		seq := parts[2]
+
+		title, indent := cutIndent(parts[1])
+		titles = append(titles, title)
+		indents = append(indents, indent)
+
+		ch := Chapter{
+			Title: title,
+		}
+		if title, ok := quirks.ChapterTitles[ch.Title]; ok {
+			ch.Title = title
+		}
+
+		switch {
+		case seqSingleRE.MatchString(seq):
+			ch.pages = append(ch.pages, seq)
+		case seqRangeRE.MatchString(seq):
+			parts := strings.Split(seq, "-")
+			start, _ := strconv.Atoi(parts[0])
+			end, _ := strconv.Atoi(parts[1])
+			if end <= start {
+				return fmt.Errorf("Not handling sequence: %q", seq)
+			}
+			for i := start; i <= end; i++ {
+				ch.pages = append(ch.pages, fmt.Sprintf("%04d", i))
+			}
+		default:
+			return fmt.Errorf("Not handling sequence: %q", seq)
+		}
+
+		chs = append(chs, ch)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Scan failed: %w", err)
+	}
+
+	nestChapters(chs, titles, indents, quirks)
+
+	// TODO Do we need to lookup each article's pages in Pages.lst?
+	labels, err := getPageLabels(fs)
+	if err != nil {
+		return err
+	}
+
+	texts := make([][]string, len(chs)) // Text of the pages of each chapter
+	var anyBFL bool                     // BlankFirstLine
+	for idx := range chs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var pages []string
+		for _, page := range chs[idx].pages {
+			if slices.Contains(quirks.SkipPages, page) {
+				continue
+			}
+
+			f, err := fs.Open(path.Join("Pages", page+".txt"))
+			if err != nil {
+				return fmt.Errorf("Open failed: %w", err)
+			}
+
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("ReadAll failed: %w", err)
+			}
+
+			if len(data) == 0 {
+				return fmt.Errorf("Page file %s is empty", page)
+			}
+
+			// Strip the CRs ASAP
+			s := strings.ReplaceAll(string(data), "\r", "")
+
+			if s, err = quirks.substitute(s); err != nil {
+				return err
+			}
+			if s == "" {
+				continue
+			}
+
+			// We assume correct formatting, meaning that pages-txts
+			// have a blank line first when the page begins with a
+			// new paragraph. But note any BlankFirstLine as
+			// heuristics for detecting if they are missing entirely.
+			if s[0] == '\n' {
+				anyBFL = true
+			}
+
+			pages = append(pages, page)
+			texts[idx] = append(texts[idx], s)
+		}
+		chs[idx].pages = pages
+	}
+
+	if !quirks.MissingBFL && !anyBFL {
+		b.MaybeMissingBFL = true
+	}
+
+	var repairBFL bool
+	if b.MaybeMissingBFL {
+		b.MissingBFLCandidates = missingBFLCandidates(texts)
+		switch opts.RepairBFL {
+		case RepairBFLAuto:
+			repairBFL = b.MissingBFLCandidates >= opts.RepairBFLMinCandidates
+		case RepairBFLAlways:
+			repairBFL = true
+		}
+	}
+
+	var vocab *process.Vocabulary
+	if !opts.KeepHyphens {
+		vocab = process.NewVocabulary(b.Language)
+		for _, pages := range texts {
+			for _, s := range pages {
+				vocab.Add(s)
+			}
+		}
+	}
+
+	for idx := range chs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var body string
+
+		for i, s := range texts[idx] {
+			if body != "" && !strings.HasSuffix(body, "\n") {
+				body += "\n"
+			}
+			page := chs[idx].pages[i]
+			var sep string
+
+			first := []rune(s)[0]
+			isLowercase := func(r rune) bool { return unicode.IsLetter(r) && unicode.IsLower(r) }
+			switch {
+			case quirks.MissingBFL:
+				// We know that these books do not have a blank line
+				// first on a page when it begins with a new
+				// paragraph. Try to deal with it by inserting a blank
+				// line when the first letter is not lowercase.
+				if first != '\n' && !isLowercase(first) {
+					sep = "\n"
+				}
+			case repairBFL && i > 0 && first != '<':
+				if ok, reason := startsParagraph(texts[idx][i-1], s); ok {
+					sep = "\n"
+					b.Repairs = append(b.Repairs, Repair{Page: chs[idx].pages[i], Reason: reason})
+				}
+			default:
+				// Still might be missing blank line before table tag
+				if strings.HasPrefix(s, "<table") {
+					sep = "\n"
+				}
+			}
+
+			// The page break goes first in the paragraph the page begins with
+			blank := s[:len(s)-len(strings.TrimLeft(s, "\n"))]
+			body += sep + blank + process.PageBreak(page, pageLabel(labels, page)) + "\n"
+			body += s[len(blank):]
+		}
+
+		if vocab != nil {
+			// After joining the pages, to get words split over them too
+			var hs []process.Hyphenation
+			body, hs = process.Dehyphenate(body, vocab)
+			for _, h := range hs {
+				b.Hyphenations = append(b.Hyphenations, Hyphenation{
+					Chapter: chs[idx].Title,
+					Split:   h.Split,
+					Result:  h.Result,
+					Reason:  h.Reason,
+				})
+			}
+		}
+
+		body, err = process.RunebergTxt(body)
+		if err != nil {
+			return err
+		}
+
+		body, err := process.RunebergHtml(body)
+		if err != nil {
+			return err
+		}
+
+		if chs[idx].Blocks, err = parseBlocks(body); err != nil {
+			return err
+		}
+	}
+
+	if len(chs) == 0 {
+		return fmt.Errorf("Got no chapters from Articles.lst")
+	}
+
+	b.Chapters = append(b.Chapters, chs...)
+
+	return nil
+}
+
+// getPageLabels returns the printed page numbers by page file, from
+// Pages.lst. Pages not in it get their number, like 5 for 0005.
+func getPageLabels(fs fs.FS) (map[string]string, error) {
+	f, err := fs.Open("Pages.lst")
+	if err != nil {
+		return nil, fmt.Errorf("Open failed: %w", err)
+	}
+	defer f.Close()
+
+	labels := map[string]string{}
+	// Expecting lines in Pages.lst like: 0005|5
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Split(decodeISO8859_1(scanner.Bytes()), "|")
+		if len(parts) < 2 || strings.HasPrefix(parts[0], "#") {
+			continue
+		}
+		if label := strings.TrimSpace(parts[1]); label != "" {
+			labels[parts[0]] = label
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Scan failed: %w", err)
+	}
+
+	return labels, nil
+}
+
+// pageLabel returns the label of the page file.
+func pageLabel(labels map[string]string, page string) string {
+	if label, ok := labels[page]; ok {
+		return label
+	}
+	return strings.TrimLeft(page, "0")
+}
+
+func (b *Book) getChaptersNoPages(ctx context.Context, fs fs.FS, quirks Quirks) error {
+	// TODO Is Articles.lst in ISO-8859-1?
+	f, err := fs.Open("Articles.lst")
+	if err != nil {
+		return fmt.Errorf("Open failed: %w", err)
+	}
+	defer f.Close()
+
+	var chs []Chapter
+	var titles []string // The headings, which quirks go by
+	var indents, headingLevels []int
+
+	// Expecting lines in Articles.lst like: htmlbasename|Titel|
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fname, rest, found := strings.Cut(scanner.Text(), "|")
+		if !found || fname == "index" || strings.HasPrefix(fname, "#") {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		f, err := fs.Open(fname + ".html")
+		if err != nil {
+			return fmt.Errorf("Open failed: %w", err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("ReadAll failed: %w", err)
+		}
+
+		body, err := quirks.substitute(string(data))
+		if err != nil {
+			return err
+		}
+
+		// TODO could get title from Articles.lst?
+		title, level := chapterHeading(body)
+		if title == "" {
+			return fmt.Errorf("no title found in %s", fname)
+		}
+		titles = append(titles, title)
+		_, indent := cutIndent(rest)
+		indents = append(indents, indent)
+		headingLevels = append(headingLevels, level)
+		if t, ok := quirks.ChapterTitles[title]; ok {
+			title = t
+		}
+
+		body, err = process.RunebergHtml(body)
+		if err != nil {
+			return err
+		}
+
+		blocks, err := parseBlocks(body)
+		if err != nil {
+			return err
+		}
+
+		chs = append(chs, Chapter{
+			Title:  title,
+			Blocks: blocks,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Scan failed: %w", err)
+	}
+
+	if len(chs) == 0 {
+		return fmt.Errorf("Got no chapters from Articles.lst")
+	}
+
+	if slices.Min(indents) == slices.Max(indents) {
+		indents = headingLevels
+	}
+	nestChapters(chs, titles, indents, quirks)
+
+	b.Chapters = append(b.Chapters, chs...)
+
+	return nil
+}
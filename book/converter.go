@@ -0,0 +1,161 @@
+package book
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+
+	"github.com/quite/runepub/internal/download"
+)
+
+// Options configures a Converter. The zero value gives the defaults.
+type Options struct {
+	// Language of the text that the converter adds, like the note on
+	// the title page. Empty means the language of the book.
+	Language string
+	// CSS replaces DefaultCSS as the stylesheet of the EPUB.
+	CSS string
+	// Quirks profiles of books with broken formatting, by titlekey.
+	// Nil means DefaultQuirks.
+	Quirks map[string]Quirks
+	// RepairBFL is whether to insert paragraph breaks at the start
+	// of pages when the book seems to be missing blank first lines
+	// (see Book.MaybeMissingBFL). With RepairBFLAuto, the default,
+	// only when Book.MissingBFLCandidates reaches
+	// RepairBFLMinCandidates (0 means 10).
+	RepairBFL              RepairBFL
+	RepairBFLMinCandidates int
+	// KeepHyphens turns off rejoining words split with a hyphen at
+	// line ends, see Book.Hyphenations.
+	KeepHyphens bool
+	// ImageBase is a site like https://runeberg.org, or a directory,
+	// to get images from that are missing in the zip-file. The files
+	// of a book are expected under its titlekey.
+	ImageBase string
+	// Downloader fetches the images from an ImageBase site, sharing
+	// its pace with other downloads. Nil means one of the converter's
+	// own, with retries.
+	Downloader *download.Downloader
+	// Cover is an image (JPEG or PNG) to use as the cover of the EPUB.
+	// Nil means generating one in CoverStyle, unless NoCover.
+	Cover      []byte
+	CoverStyle CoverStyle
+	NoCover    bool
+	// Logger gets notes about the conversion. Nil discards them.
+	Logger *log.Logger
+}
+
+// RepairBFL is when to repair missing blank first lines.
+type RepairBFL int
+
+const (
+	RepairBFLAuto RepairBFL = iota
+	RepairBFLOff
+	RepairBFLAlways
+)
+
+// Converter converts Runeberg books to EPUB. It is safe for
+// concurrent use.
+type Converter struct {
+	opts Options
+}
+
+func NewConverter(opts Options) *Converter {
+	if opts.Quirks == nil {
+		opts.Quirks = DefaultQuirks
+	}
+	if opts.CSS == "" {
+		opts.CSS = DefaultCSS
+	}
+	if opts.RepairBFLMinCandidates == 0 {
+		opts.RepairBFLMinCandidates = defaultRepairBFLMinCandidates
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(io.Discard, "", 0)
+	}
+	if opts.Downloader == nil {
+		opts.Downloader = &download.Downloader{Retries: 3, Logf: opts.Logger.Printf}
+	}
+	return &Converter{opts: opts}
+}
+
+// Convert reads a book from a Runeberg zip-file, typically named
+// titlekey-txt.zip.
+func (c *Converter) Convert(ctx context.Context, zipData []byte) (*Book, error) {
+	r, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotZip, err)
+	}
+
+	return c.ConvertFS(ctx, r)
+}
+
+// ConvertFS reads a book from the files of a Runeberg zip-file, for
+// example an unpacked one using os.DirFS.
+func (c *Converter) ConvertFS(ctx context.Context, fsys fs.FS) (*Book, error) {
+	b := &Book{}
+
+	if err := b.getMetadata(fsys); err != nil {
+		return nil, &SourceError{File: "Metadata", Err: err}
+	}
+
+	lang := c.lang(b)
+	quirks := c.opts.Quirks[b.TitleKey]
+
+	if err := b.getFrontmatter(fsys, lang, quirks); err != nil {
+		return nil, &SourceError{File: "index.html", Err: err}
+	}
+
+	if _, err := fs.Stat(fsys, "Pages.lst"); err == nil {
+		err = b.getChapters(ctx, fsys, quirks, c.opts)
+		if err != nil {
+			return nil, c.sourceError(ctx, err)
+		}
+	} else {
+		if err = b.getChaptersNoPages(ctx, fsys, quirks); err != nil {
+			return nil, c.sourceError(ctx, err)
+		}
+	}
+
+	if err := c.getImages(ctx, b, fsys); err != nil {
+		return nil, err
+	}
+
+	for _, ct := range b.UnknownContributors {
+		c.opts.Logger.Printf("%s: unknown %s key %s, not credited", b.TitleKey, ct.Role, ct.Key)
+	}
+	if b.MaybeMissingBFL {
+		c.opts.Logger.Printf("%s: book maybe missing blank first line for new paragraph (%d candidate pages)",
+			b.TitleKey, b.MissingBFLCandidates)
+	}
+	for _, r := range b.Repairs {
+		c.opts.Logger.Printf("%s: page %s: %s", b.TitleKey, r.Page, r.Reason)
+	}
+	for _, h := range b.Hyphenations {
+		c.opts.Logger.Printf("%s: %s: %s made %q (%s)", b.TitleKey, h.Chapter, h.Split, h.Result, h.Reason)
+	}
+
+	return b, nil
+}
+
+// sourceError wraps errors from reading the chapters, except for
+// cancellation.
+func (c *Converter) sourceError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return &SourceError{File: "Articles.lst", Err: err}
+}
+
+// lang returns the language of the text that the converter adds to
+// the book.
+func (c *Converter) lang(b *Book) string {
+	if c.opts.Language != "" {
+		return c.opts.Language
+	}
+	return b.Language
+}
@@ -0,0 +1,213 @@
+package book
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of generated covers, in pixels
+const (
+	coverWidth  = 1200
+	coverHeight = 1800
+)
+
+// CoverScheme is the colours of a generated cover.
+type CoverScheme struct {
+	Name       string
+	Background color.RGBA
+	Text       color.RGBA
+	Accent     color.RGBA
+}
+
+// CoverSchemes are the colour schemes of generated covers.
+var CoverSchemes = []CoverScheme{
+	{"ink", color.RGBA{0x1d, 0x2a, 0x3a, 0xff}, color.RGBA{0xf2, 0xea, 0xd8, 0xff}, color.RGBA{0xc8, 0x9b, 0x3c, 0xff}},
+	{"forest", color.RGBA{0x24, 0x3b, 0x2f, 0xff}, color.RGBA{0xee, 0xe8, 0xd5, 0xff}, color.RGBA{0xb5, 0x8d, 0x4a, 0xff}},
+	{"wine", color.RGBA{0x5a, 0x1e, 0x2b, 0xff}, color.RGBA{0xf4, 0xe9, 0xdc, 0xff}, color.RGBA{0xd9, 0xb0, 0x6c, 0xff}},
+	{"sea", color.RGBA{0x1f, 0x4e, 0x5f, 0xff}, color.RGBA{0xf0, 0xf4, 0xf2, 0xff}, color.RGBA{0xe0, 0x9f, 0x3e, 0xff}},
+	{"paper", color.RGBA{0xf3, 0xec, 0xdc, 0xff}, color.RGBA{0x2b, 0x25, 0x1f, 0xff}, color.RGBA{0x8c, 0x2f, 0x25, 0xff}},
+	{"slate", color.RGBA{0x3b, 0x3f, 0x45, 0xff}, color.RGBA{0xf1, 0xf1, 0xee, 0xff}, color.RGBA{0x9f, 0xb8, 0xad, 0xff}},
+}
+
+// CoverSchemeNames returns the names of CoverSchemes.
+func CoverSchemeNames() []string {
+	var names []string
+	for _, s := range CoverSchemes {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// CoverLayouts are the layouts of generated covers: "classic" (a
+// framed title), "band" (the title on a band across the cover) and
+// "frame" (a broad frame).
+var CoverLayouts = []string{"classic", "band", "frame"}
+
+// CoverStyle is how a generated cover looks. Empty fields are picked
+// from the titlekey, so that a book always gets the same cover.
+type CoverStyle struct {
+	Layout string // One of CoverLayouts
+	Scheme string // Name of one of CoverSchemes
+}
+
+// Cover generates a PNG cover image with the title, author and year
+// of the book, and the source.
+func (b *Book) Cover(style CoverStyle) ([]byte, error) {
+	h := fnv.New32a()
+	h.Write([]byte(b.TitleKey))
+	sum := int(h.Sum32() & 0x7fffffff)
+
+	layout := style.Layout
+	if layout == "" {
+		layout = CoverLayouts[sum%len(CoverLayouts)]
+	}
+	scheme := CoverSchemes[(sum/len(CoverLayouts))%len(CoverSchemes)]
+	if style.Scheme != "" {
+		found := false
+		for _, s := range CoverSchemes {
+			if s.Name == style.Scheme {
+				scheme, found = s, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown cover scheme %q", style.Scheme)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, coverWidth, coverHeight))
+	fill(img, img.Bounds(), scheme.Background)
+
+	textColor, titleColor := scheme.Text, scheme.Text
+	titleTop, titleBottom := 380, 1000
+	switch layout {
+	case "classic":
+		fill(img, image.Rect(60, 60, coverWidth-60, coverHeight-60), scheme.Accent)
+		fill(img, image.Rect(72, 72, coverWidth-72, coverHeight-72), scheme.Background)
+		fill(img, image.Rect(90, 90, coverWidth-90, coverHeight-90), scheme.Accent)
+		fill(img, image.Rect(96, 96, coverWidth-96, coverHeight-96), scheme.Background)
+		fill(img, image.Rect(coverWidth/2-120, 1060, coverWidth/2+120, 1068), scheme.Accent)
+	case "band":
+		fill(img, image.Rect(0, titleTop-60, coverWidth, titleBottom+60), scheme.Accent)
+		titleColor = scheme.Background
+		fill(img, image.Rect(0, titleTop-76, coverWidth, titleTop-68), scheme.Text)
+		fill(img, image.Rect(0, titleBottom+68, coverWidth, titleBottom+76), scheme.Text)
+	case "frame":
+		fill(img, img.Bounds(), scheme.Accent)
+		fill(img, image.Rect(110, 110, coverWidth-110, coverHeight-110), scheme.Background)
+		titleTop = 300
+	default:
+		return nil, fmt.Errorf("unknown cover layout %q", layout)
+	}
+
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("Parse failed: %w", err)
+	}
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("Parse failed: %w", err)
+	}
+	italic, err := opentype.Parse(goitalic.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("Parse failed: %w", err)
+	}
+
+	// The title gets the largest size that fits it between titleTop
+	// and titleBottom
+	var title []string
+	var titleFace font.Face
+	for size := 120.0; size >= 48; size -= 8 {
+		if titleFace, err = coverFace(bold, size); err != nil {
+			return nil, err
+		}
+		title = wrapText(titleFace, b.Title, coverWidth-300)
+		if len(title)*int(size*1.25) <= titleBottom-titleTop || size <= 48 {
+			break
+		}
+	}
+	lineHeight := titleFace.Metrics().Height.Ceil() * 5 / 4
+	y := titleTop + (titleBottom-titleTop-len(title)*lineHeight)/2 + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range title {
+		drawCentered(img, titleFace, line, y, titleColor)
+		y += lineHeight
+	}
+
+	authorFace, err := coverFace(regular, 64)
+	if err != nil {
+		return nil, err
+	}
+	y = 1220
+	for _, line := range wrapText(authorFace, b.Author, coverWidth-300) {
+		drawCentered(img, authorFace, line, y, textColor)
+		y += authorFace.Metrics().Height.Ceil() * 5 / 4
+	}
+
+	smallFace, err := coverFace(italic, 44)
+	if err != nil {
+		return nil, err
+	}
+	if b.Year != "" {
+		drawCentered(img, smallFace, b.Year, y+40, textColor)
+	}
+	drawCentered(img, smallFace, "Projekt Runeberg · runeberg.org", coverHeight-190, textColor)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("Encode failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func coverFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("NewFace failed: %w", err)
+	}
+	return face, nil
+}
+
+// wrapText breaks the text into lines no wider than width, breaking
+// between words.
+func wrapText(face font.Face, text string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && font.MeasureString(face, line+" "+word).Ceil() > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// drawCentered draws a line of text centered, with the baseline at y.
+func drawCentered(img *image.RGBA, face font.Face, text string, y int, c color.Color) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+	x := (coverWidth - d.MeasureString(text).Ceil()) / 2
+	d.Dot = fixed.P(x, y)
+	d.DrawString(text)
+}
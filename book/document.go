@@ -0,0 +1,295 @@
+package book
+
+// The document model of the text of a book. A chapter is a list of
+// blocks, which hold inline content. Writers of output formats walk
+// this, rather than any markup.
+
+// Block is a block-level part of a chapter: Paragraph, Heading,
+// Verse, Table, List, Preformatted, Rule, Div, SourceNote or
+// PageBreak.
+type Block interface {
+	isBlock()
+}
+
+// Inline is a part of the content of a block: Text, Span, Link,
+// LineBreak, Note, Image or PageBreak.
+type Inline interface {
+	isInline()
+}
+
+type Paragraph struct {
+	Class   string // Like "center", or empty
+	Content []Inline
+}
+
+type Heading struct {
+	Level   int // 1 to 6
+	Content []Inline
+}
+
+// Verse is lines broken on purpose, as in verse, addresses or
+// signatures.
+type Verse struct {
+	Lines [][]Inline
+}
+
+type Table struct {
+	Align string // left, center, right or empty
+	Rows  []Row
+}
+
+type Row struct {
+	Cells []Cell
+}
+
+type Cell struct {
+	Colspan int    // 0 means 1
+	Align   string // left, center, right or empty
+	Content []Inline
+}
+
+// List is a bulleted, numbered or description list.
+type List struct {
+	Kind  ListKind
+	Items []ListItem
+}
+
+type ListKind int
+
+const (
+	Bulleted ListKind = iota
+	Numbered
+	Described // Terms with descriptions, like a glossary
+)
+
+type ListItem struct {
+	Term   []Inline // Of a Described list
+	Blocks []Block
+}
+
+// Preformatted is text whose whitespace and line breaks are kept as
+// they are.
+type Preformatted struct {
+	Content []Inline
+}
+
+// Rule is a horizontal rule, a thematic break.
+type Rule struct{}
+
+// Div groups blocks, like a centered part.
+type Div struct {
+	Class  string
+	Blocks []Block
+}
+
+// SourceNote tells where the book comes from, on the title page. Each
+// writer words it for its format.
+type SourceNote struct {
+	URLs []string
+}
+
+// PageBreak marks where a page of the printed book begins. It is
+// both a Block and an Inline, as pages may begin in a paragraph.
+type PageBreak struct {
+	Page  string // The page file, like 0005 for Pages/0005.txt
+	Label string // The printed page number, like "5" or "xii"
+}
+
+func (Paragraph) isBlock()    {}
+func (Heading) isBlock()      {}
+func (Verse) isBlock()        {}
+func (Table) isBlock()        {}
+func (List) isBlock()         {}
+func (Preformatted) isBlock() {}
+func (Rule) isBlock()         {}
+func (Div) isBlock()          {}
+func (SourceNote) isBlock()   {}
+func (PageBreak) isBlock()    {}
+
+type Text string
+
+// Style is the typographic style of a Span.
+type Style int
+
+const (
+	Emphasis Style = iota
+	Strong
+	Underline
+	SmallCaps
+	Spaced // Letter-spaced, for emphasis
+	Big
+	Sup
+	Sub
+)
+
+type Span struct {
+	Style   Style
+	Content []Inline
+}
+
+type Link struct {
+	URL     string
+	Content []Inline
+}
+
+type LineBreak struct{}
+
+// Note is a footnote, placed where it is referenced.
+type Note struct {
+	Content []Inline
+}
+
+type Image struct {
+	Src string // Path in the book, or URL
+	Alt string
+}
+
+func (Text) isInline()      {}
+func (Span) isInline()      {}
+func (Link) isInline()      {}
+func (LineBreak) isInline() {}
+func (Note) isInline()      {}
+func (Image) isInline()     {}
+func (PageBreak) isInline() {}
+
+// paragraph returns the note in the language, worded for an EPUB or
+// for other formats.
+func (n SourceNote) paragraph(lang string, epub bool) Paragraph {
+	key := "sourcetext"
+	if epub {
+		key = "source"
+	}
+	content := []Inline{Text(msg(lang, key) + ": ")}
+	for i, u := range n.URLs {
+		if i > 0 {
+			content = append(content, Text(", "))
+		}
+		content = append(content, Link{URL: u, Content: []Inline{Text(u)}})
+	}
+	return Paragraph{Content: append(content, Text("."))}
+}
+
+// PlainText returns the text of the content, without any markup.
+func PlainText(content []Inline) string {
+	var s string
+	for _, in := range content {
+		switch in := in.(type) {
+		case Text:
+			s += string(in)
+		case Span:
+			s += PlainText(in.Content)
+		case Link:
+			s += PlainText(in.Content)
+		case LineBreak:
+			s += "\n"
+		case Note:
+			s += " [" + PlainText(in.Content) + "]"
+		}
+	}
+	return s
+}
+
+// PageBreaks returns the page breaks in the blocks, in order.
+func PageBreaks(blocks []Block) []PageBreak {
+	var pbs []PageBreak
+	var content func([]Inline)
+	content = func(ins []Inline) {
+		for _, in := range ins {
+			switch in := in.(type) {
+			case PageBreak:
+				pbs = append(pbs, in)
+			case Span:
+				content(in.Content)
+			case Link:
+				content(in.Content)
+			}
+		}
+	}
+
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case PageBreak:
+			pbs = append(pbs, b)
+		case Paragraph:
+			content(b.Content)
+		case Heading:
+			content(b.Content)
+		case Verse:
+			for _, line := range b.Lines {
+				content(line)
+			}
+		case Table:
+			for _, row := range b.Rows {
+				for _, cell := range row.Cells {
+					content(cell.Content)
+				}
+			}
+		case List:
+			for _, item := range b.Items {
+				content(item.Term)
+				pbs = append(pbs, PageBreaks(item.Blocks)...)
+			}
+		case Preformatted:
+			content(b.Content)
+		case Div:
+			pbs = append(pbs, PageBreaks(b.Blocks)...)
+		}
+	}
+	return pbs
+}
+
+// mapPageBreaks replaces each PageBreak in the blocks by what f
+// returns.
+func mapPageBreaks(blocks []Block, f func(PageBreak) PageBreak) []Block {
+	var content func([]Inline) []Inline
+	content = func(ins []Inline) []Inline {
+		for i, in := range ins {
+			switch in := in.(type) {
+			case PageBreak:
+				ins[i] = f(in)
+			case Span:
+				in.Content = content(in.Content)
+				ins[i] = in
+			case Link:
+				in.Content = content(in.Content)
+				ins[i] = in
+			}
+		}
+		return ins
+	}
+
+	for i, b := range blocks {
+		switch b := b.(type) {
+		case PageBreak:
+			blocks[i] = f(b)
+		case Paragraph:
+			b.Content = content(b.Content)
+			blocks[i] = b
+		case Heading:
+			b.Content = content(b.Content)
+			blocks[i] = b
+		case Verse:
+			for j := range b.Lines {
+				b.Lines[j] = content(b.Lines[j])
+			}
+		case Table:
+			for _, row := range b.Rows {
+				for j := range row.Cells {
+					row.Cells[j].Content = content(row.Cells[j].Content)
+				}
+			}
+		case List:
+			for j := range b.Items {
+				b.Items[j].Term = content(b.Items[j].Term)
+				b.Items[j].Blocks = mapPageBreaks(b.Items[j].Blocks, f)
+			}
+		case Preformatted:
+			b.Content = content(b.Content)
+			blocks[i] = b
+		case Div:
+			b.Blocks = mapPageBreaks(b.Blocks, f)
+			blocks[i] = b
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,238 @@
+package book
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-shiori/go-epub"
+)
+
+// DefaultCSS is the stylesheet of the EPUB, unless replaced in the
+// Options.
+const DefaultCSS = `
+p {
+  text-indent: 0;
+  margin-top: 0;
+}
+
+p + p {
+  margin-top: 1.5ex;
+}
+
+h1, h2, h3,
+p.center, div.center {
+  text-align: center;
+}
+
+p.verse {
+  margin-left: 1em;
+}
+
+pre {
+  white-space: pre-wrap;
+}
+
+dt {
+  font-weight: bold;
+}
+
+hr {
+  border: 1px solid black;
+}
+
+span.spaced {
+  letter-spacing: 0.1rem;
+}
+
+span.smallcaps {
+  font-variant: small-caps;
+}
+
+span.big {
+  font-size: 130%;
+}
+
+section.footnotes {
+  font-size: 80%;
+  margin-top: 3ex;
+}
+
+section.footnotes h2 {
+  font-size: 100%;
+  text-align: left;
+}
+
+td._c {
+  text-align: center;
+}
+td._r {
+  text-align: right;
+}
+`
+
+// WriteEPUB writes the book as EPUB using the default options, see
+// Converter.
+func (b *Book) WriteEPUB(w io.Writer) error {
+	return NewConverter(Options{}).WriteEPUB(context.Background(), b, w)
+}
+
+// WriteEPUB writes the book as EPUB.
+func (c *Converter) WriteEPUB(ctx context.Context, b *Book, w io.Writer) error {
+	e, err := epub.NewEpub(b.Title)
+	if err != nil {
+		return fmt.Errorf("NewEpub failed: %w", err)
+	}
+
+	e.SetLang(b.mainLanguage())
+	e.SetIdentifier(b.identifier())
+
+	dataURI := fmt.Sprintf("data:%s;base64,%s", "text/css", base64.StdEncoding.EncodeToString([]byte(c.opts.CSS)))
+
+	cssPath, err := e.AddCSS(dataURI, "style.css")
+	if err != nil {
+		return fmt.Errorf("AddCSS failed: %w", err)
+	}
+
+	if !c.opts.NoCover {
+		if err = c.addCover(e, b); err != nil {
+			return err
+		}
+	}
+
+	images, err := addImages(e, b)
+	if err != nil {
+		return err
+	}
+
+	var pageList []string // Entries of the page-list nav
+	var parents []string  // Filenames of the sections by level
+	for _, ch := range b.Chapters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		body := renderXHTML(ch.Blocks, c.lang(b), images)
+		level := min(max(ch.Level, 0), len(parents))
+		var filename string
+		if level == 0 {
+			filename, err = e.AddSection(body, ch.Title, "", cssPath)
+		} else {
+			filename, err = e.AddSubSection(parents[level-1], body, ch.Title, "", cssPath)
+		}
+		if err != nil {
+			return fmt.Errorf("AddSection failed: %w", err)
+		}
+		parents = append(parents[:level], filename)
+		for _, pb := range PageBreaks(ch.Blocks) {
+			pageList = append(pageList, fmt.Sprintf(`<li><a href="xhtml/%s#%s">%s</a></li>`,
+				filename, pageBreakID(pb), html.EscapeString(pb.Label)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err = e.WriteTo(&buf); err != nil {
+		return fmt.Errorf("WriteTo failed: %w", err)
+	}
+
+	metadata := b.contributorsMetadata()
+	if langs := b.languages(); len(langs) > 1 {
+		// SetLang takes only one
+		for _, lang := range langs[1:] {
+			metadata = append(metadata, fmt.Sprintf("<dc:language>%s</dc:language>", html.EscapeString(lang)))
+		}
+	}
+	for _, u := range b.sources() {
+		metadata = append(metadata, fmt.Sprintf("<dc:source>%s</dc:source>", html.EscapeString(u)))
+	}
+	if len(pageList) > 0 {
+		metadata = append(metadata, b.pageListMetadata()...)
+	}
+	if b.Collection != "" {
+		metadata = append(metadata, b.collectionMetadata()...)
+	}
+
+	fixes := map[string]fixFunc{
+		"EPUB/package.opf": func(data []byte) ([]byte, error) {
+			return insertOPFMetadata(data, metadata)
+		},
+		"EPUB/nav.xhtml": func(data []byte) ([]byte, error) {
+			data, err := localizeNav(data, msg(c.lang(b), "contents"))
+			if err != nil || len(pageList) == 0 {
+				return data, err
+			}
+			return insertNav(data, "page-list", pageList)
+		},
+	}
+
+	return rewriteEPUB(buf.Bytes(), w, fixes)
+}
+
+// addImages adds the images of the book, returning their paths in
+// the EPUB by their paths in the book.
+func addImages(e *epub.Epub, b *Book) (map[string]string, error) {
+	var paths []string
+	for p := range b.Images {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	images := map[string]string{}
+	for _, p := range paths {
+		dataURI := imageDataURI(p, b.Images[p])
+		// Keeping the directories in the name, to keep it unique
+		imgPath, err := e.AddImage(dataURI, strings.ReplaceAll(p, "/", "-"))
+		if err != nil {
+			return nil, fmt.Errorf("AddImage failed: %w", err)
+		}
+		images[p] = imgPath
+	}
+	return images, nil
+}
+
+// imageDataURI returns a data URI of the image at path p in the book.
+func imageDataURI(p string, data []byte) string {
+	mediaType := mime.TypeByExtension(path.Ext(p))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+func (c *Converter) addCover(e *epub.Epub, b *Book) error {
+	data := c.opts.Cover
+	if data == nil {
+		var err error
+		if data, err = b.Cover(c.opts.CoverStyle); err != nil {
+			return fmt.Errorf("Cover failed: %w", err)
+		}
+	}
+
+	var ext string
+	mediaType := http.DetectContentType(data)
+	switch mediaType {
+	case "image/png":
+		ext = ".png"
+	case "image/jpeg":
+		ext = ".jpg"
+	default:
+		return fmt.Errorf("cover is %s, not PNG or JPEG", mediaType)
+	}
+
+	dataURI := fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(data))
+	imgPath, err := e.AddImage(dataURI, "runepub-cover"+ext)
+	if err != nil {
+		return fmt.Errorf("AddImage failed: %w", err)
+	}
+	if err = e.SetCover(imgPath, ""); err != nil {
+		return fmt.Errorf("SetCover failed: %w", err)
+	}
+	return nil
+}
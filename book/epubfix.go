@@ -0,0 +1,201 @@
+package book
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// fixFunc rewrites the contents of a file in the EPUB.
+type fixFunc func(data []byte) ([]byte, error)
+
+// rewriteEPUB copies the EPUB in data to w, passing the files named
+// in fixes through their fixFunc. go-epub does not let us write all
+// the markup we need, so we patch its output instead.
+func rewriteEPUB(data []byte, w io.Writer, fixes map[string]fixFunc) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("NewReader failed: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range r.File {
+		f, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("Open failed: %w", err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("ReadAll failed: %w", err)
+		}
+
+		if fix, ok := fixes[file.Name]; ok {
+			if content, err = fix(content); err != nil {
+				return fmt.Errorf("fixing %s failed: %w", file.Name, err)
+			}
+		}
+
+		// The mimetype file stays first and uncompressed
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:   file.Name,
+			Method: file.Method,
+		})
+		if err != nil {
+			return fmt.Errorf("CreateHeader failed: %w", err)
+		}
+		if _, err = fw.Write(content); err != nil {
+			return fmt.Errorf("Write failed: %w", err)
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("Close failed: %w", err)
+	}
+
+	return nil
+}
+
+var opfMetadataEndRE = regexp.MustCompile(`\n?\s*</metadata>`)
+
+// insertOPFMetadata adds the elements at the end of the metadata in
+// package.opf.
+func insertOPFMetadata(opf []byte, elements []string) ([]byte, error) {
+	loc := opfMetadataEndRE.FindIndex(opf)
+	if loc == nil {
+		return nil, fmt.Errorf("no </metadata> found")
+	}
+
+	var s strings.Builder
+	for _, el := range elements {
+		s.WriteString("\n    " + el)
+	}
+
+	var out []byte
+	out = append(out, opf[:loc[0]]...)
+	out = append(out, s.String()...)
+	out = append(out, opf[loc[0]:]...)
+	return out, nil
+}
+
+// contributorsMetadata returns the creator and contributor elements
+// for the book's contributors, with roles and sorting names. Authors
+// are creators, others are contributors.
+func (b *Book) contributorsMetadata() []string {
+	var elements []string
+	for i, c := range b.Contributors {
+		el := "dc:contributor"
+		if c.Role != RoleTranslator {
+			el = "dc:creator"
+		}
+		id := fmt.Sprintf("contributor%d", i+1)
+		elements = append(elements,
+			fmt.Sprintf(`<%s id="%s">%s</%[1]s>`, el, id, html.EscapeString(c.Name)),
+			fmt.Sprintf(`<meta refines="#%s" property="role" scheme="marc:relators">%s</meta>`, id, c.Role.Relator()),
+			fmt.Sprintf(`<meta refines="#%s" property="file-as">%s</meta>`, id, html.EscapeString(c.FileAs)),
+		)
+	}
+	return elements
+}
+
+// identifier returns the unique identifier of the EPUB: the URL of
+// the book, unless it is assembled from several or a part of one.
+func (b *Book) identifier() string {
+	if b.URL == "" || b.Collection != "" {
+		return "urn:runepub:" + b.TitleKey
+	}
+	return b.URL
+}
+
+// sources returns the URLs of the book at Runeberg, one for each
+// volume if assembled.
+func (b *Book) sources() []string {
+	if len(b.Volumes) == 0 {
+		return []string{b.URL}
+	}
+	var urls []string
+	for _, vol := range b.Volumes {
+		urls = append(urls, vol.sources()...)
+	}
+	return urls
+}
+
+// languages returns the codes of the languages of the book, as
+// Language can have several, like "da no sv".
+func (b *Book) languages() []string {
+	return strings.Fields(b.Language)
+}
+
+// mainLanguage returns the first language of the book, for where only
+// one fits, like the lang attribute. Or "" if unknown.
+func (b *Book) mainLanguage() string {
+	if langs := b.languages(); len(langs) > 0 {
+		return langs[0]
+	}
+	return ""
+}
+
+var navTOCHeadingRE = regexp.MustCompile(`<h1>Table of Contents</h1>`)
+
+// localizeNav replaces the heading of the table of contents, which
+// go-epub has in English.
+func localizeNav(nav []byte, heading string) ([]byte, error) {
+	loc := navTOCHeadingRE.FindIndex(nav)
+	if loc == nil {
+		return nil, fmt.Errorf("no table of contents heading found")
+	}
+	var buf bytes.Buffer
+	buf.Write(nav[:loc[0]])
+	fmt.Fprintf(&buf, "<h1>%s</h1>", html.EscapeString(heading))
+	buf.Write(nav[loc[1]:])
+	return buf.Bytes(), nil
+}
+
+var navBodyEndRE = regexp.MustCompile(`</body>`)
+
+// insertNav inserts a hidden nav element of the type, with the list
+// items, last in the nav document.
+func insertNav(nav []byte, navType string, items []string) ([]byte, error) {
+	loc := navBodyEndRE.FindIndex(nav)
+	if loc == nil {
+		return nil, fmt.Errorf("no </body> found")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(nav[:loc[0]])
+	fmt.Fprintf(&buf, "    <nav epub:type=\"%s\" hidden=\"\">\n      <ol>\n", navType)
+	for _, item := range items {
+		buf.WriteString("        " + item + "\n")
+	}
+	buf.WriteString("      </ol>\n    </nav>\n")
+	buf.Write(nav[loc[0]:])
+	return buf.Bytes(), nil
+}
+
+// pageListMetadata returns metadata telling that the book has the page
+// numbers of the printed edition.
+func (b *Book) pageListMetadata() []string {
+	source := b.Title
+	if b.Year != "" {
+		source += ", " + b.Year
+	}
+	return []string{
+		fmt.Sprintf(`<meta property="a11y:pageBreakSource">%s</meta>`, html.EscapeString(source)),
+		`<meta property="schema:accessibilityFeature">printPageNumbers</meta>`,
+		`<meta property="schema:accessibilityFeature">pageNavigation</meta>`,
+	}
+}
+
+// collectionMetadata returns metadata telling the collection that the
+// book is a part of, and its number in it.
+func (b *Book) collectionMetadata() []string {
+	return []string{
+		fmt.Sprintf(`<meta property="belongs-to-collection" id="collection">%s</meta>`, html.EscapeString(b.Collection)),
+		`<meta refines="#collection" property="collection-type">series</meta>`,
+		fmt.Sprintf(`<meta refines="#collection" property="group-position">%d</meta>`, b.CollectionIndex),
+	}
+}
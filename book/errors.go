@@ -0,0 +1,39 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotZip is returned when the input cannot be read as a zip-file.
+var ErrNotZip = errors.New("not a zip-file")
+
+// MetadataError is returned when a field in the Metadata file of a
+// book is missing or has a bad value.
+type MetadataError struct {
+	Field string // e.g. AUTHORKEY
+	Value string
+	Msg   string
+}
+
+func (e *MetadataError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s %s", e.Field, e.Msg)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Field, e.Msg, e.Value)
+}
+
+// SourceError is returned when a file of the book could not be read
+// or understood.
+type SourceError struct {
+	File string // The file, or the list of files, that failed
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,157 @@
+package book
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Styles for the single HTML file, besides those of the EPUB
+const htmlCSS = `
+body {
+  max-width: 40em;
+  margin: 0 auto;
+  padding: 0 1em;
+}
+
+nav.toc ol {
+  list-style: none;
+}
+
+section.chapter {
+  break-before: page;
+}
+
+img {
+  max-width: 100%;
+}
+
+@media print {
+  nav.toc a {
+    color: inherit;
+    text-decoration: none;
+  }
+}
+`
+
+// WriteHTML writes the book as a single HTML file using the default
+// options, see Converter.
+func (b *Book) WriteHTML(w io.Writer) error {
+	return NewConverter(Options{}).WriteHTML(context.Background(), b, w)
+}
+
+// WriteHTML writes the book as a single, self-contained HTML file
+// (polyglot HTML5 and XHTML), for publishing on the web or printing.
+// The stylesheet is inlined and the images are data URIs. A table of
+// contents links to the chapters, and the metadata is in Dublin Core
+// meta tags.
+func (c *Converter) WriteHTML(ctx context.Context, b *Book, w io.Writer) error {
+	lang := c.lang(b)
+	images := map[string]string{}
+	for p, data := range b.Images {
+		images[p] = imageDataURI(p, data)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%[1]s" xml:lang="%[1]s">
+<head>
+<meta charset="utf-8"/>
+<title>%[2]s</title>
+`, html.EscapeString(b.mainLanguage()), html.EscapeString(b.Title))
+	for _, m := range b.htmlMetadata() {
+		sb.WriteString(m + "\n")
+	}
+	fmt.Fprintf(&sb, "<style>\n%s%s</style>\n</head>\n<body>\n", c.opts.CSS, htmlCSS)
+
+	writeHTMLTOC(&sb, b.Chapters, lang)
+
+	for i, ch := range b.Chapters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		xw := &xhtmlWriter{lang: lang, images: images, idPrefix: chapterID(i) + "-"}
+		fmt.Fprintf(&sb, "<section class=\"chapter\" id=\"%s\">%s</section>\n\n",
+			chapterID(i), xw.render(ch.Blocks))
+	}
+	sb.WriteString("</body>\n</html>\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("WriteString failed: %w", err)
+	}
+	return nil
+}
+
+func chapterID(i int) string {
+	return fmt.Sprintf("chapter%d", i+1)
+}
+
+// htmlMetadata returns the meta tags of the book, in Dublin Core.
+func (b *Book) htmlMetadata() []string {
+	meta := func(name, content string) string {
+		return fmt.Sprintf(`<meta name="%s" content="%s"/>`, name, html.EscapeString(content))
+	}
+
+	tags := []string{
+		`<link rel="schema.DC" href="http://purl.org/dc/elements/1.1/"/>`,
+		meta("DC.title", b.Title),
+	}
+	for _, lang := range b.languages() {
+		tags = append(tags, meta("DC.language", lang))
+	}
+	tags = append(tags, meta("DC.identifier", b.identifier()))
+	if b.Author != "" {
+		tags = append(tags, meta("author", b.Author))
+	}
+	for _, ct := range b.Contributors {
+		if ct.Role == RoleTranslator {
+			tags = append(tags, meta("DC.contributor", ct.Name))
+		} else {
+			tags = append(tags, meta("DC.creator", ct.Name))
+		}
+	}
+	if b.Year != "" {
+		tags = append(tags, meta("DC.date", b.Year))
+	}
+	for _, u := range b.sources() {
+		tags = append(tags, meta("DC.source", u))
+	}
+	if b.Collection != "" {
+		tags = append(tags, meta("DC.relation", b.Collection))
+	}
+	return tags
+}
+
+// writeHTMLTOC writes the table of contents, nested by the levels of
+// the chapters.
+func writeHTMLTOC(sb *strings.Builder, chs Chapters, lang string) {
+	fmt.Fprintf(sb, "<nav class=\"toc\" epub:type=\"toc\">\n<h2>%s</h2>\n<ol>\n",
+		html.EscapeString(msg(lang, "contents")))
+	level := 0
+	for i, ch := range chs {
+		l := min(max(ch.Level, 0), level+1)
+		if i > 0 {
+			if l > level {
+				sb.WriteString("\n<ol>\n")
+			} else {
+				sb.WriteString("</li>\n")
+				for ; level > l; level-- {
+					sb.WriteString("</ol></li>\n")
+				}
+			}
+		} else {
+			l = 0
+		}
+		level = l
+		fmt.Fprintf(sb, `<li><a href="#%s">%s</a>`, chapterID(i), html.EscapeString(ch.Title))
+	}
+	if len(chs) > 0 {
+		sb.WriteString("</li>\n")
+	}
+	for ; level > 0; level-- {
+		sb.WriteString("</ol></li>\n")
+	}
+	sb.WriteString("</ol>\n</nav>\n\n")
+}
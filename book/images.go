@@ -0,0 +1,179 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// imagePath returns the path in the book of the image src, which may
+// be relative to the book, or an absolute path or URL on runeberg.org.
+func imagePath(titleKey, src string) (string, bool) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "", false
+	}
+	if u.IsAbs() && u.Host != "runeberg.org" && u.Host != "www.runeberg.org" {
+		return "", false
+	}
+
+	p := u.Path
+	if strings.HasPrefix(p, "/") {
+		var ok bool
+		if p, ok = strings.CutPrefix(p, "/"+titleKey+"/"); !ok {
+			return "", false
+		}
+	}
+	p = path.Clean(p)
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	return p, true
+}
+
+// getImages gets the images of the book from fsys, or else from
+// Options.ImageBase, into b.Images, pointing the Image inlines at
+// them. Missing images are noted in b.MissingImages and replaced by
+// their alt text.
+func (c *Converter) getImages(ctx context.Context, b *Book, fsys fs.FS) error {
+	b.Images = map[string][]byte{}
+	missing := map[string]bool{}
+
+	var err error
+	resolve := func(img Image) []Inline {
+		p, ok := imagePath(b.TitleKey, img.Src)
+		if ok && err == nil && b.Images[p] == nil && !missing[p] {
+			var data []byte
+			data, err = c.readImage(ctx, fsys, b.TitleKey, p)
+			if err == nil {
+				b.Images[p] = data
+			} else if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+				missing[p] = true
+			}
+		}
+		if err != nil {
+			// Giving up anyway
+			return []Inline{img}
+		}
+
+		if !ok || missing[p] {
+			if !slices.Contains(b.MissingImages, img.Src) {
+				b.MissingImages = append(b.MissingImages, img.Src)
+			}
+			if img.Alt == "" {
+				return nil
+			}
+			return []Inline{Text("[" + img.Alt + "]")}
+		}
+
+		img.Src = p
+		return []Inline{img}
+	}
+
+	for i := range b.Chapters {
+		b.Chapters[i].Blocks = mapImages(b.Chapters[i].Blocks, resolve)
+	}
+	if err != nil {
+		return fmt.Errorf("getting image failed: %w", err)
+	}
+
+	for _, src := range b.MissingImages {
+		c.opts.Logger.Printf("%s: missing image %s", b.TitleKey, src)
+	}
+
+	return nil
+}
+
+// readImage reads the image from fsys, or else from Options.ImageBase.
+// An image that could not be fetched is logged, and reported as not
+// existing.
+func (c *Converter) readImage(ctx context.Context, fsys fs.FS, titleKey, p string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, p)
+	if !errors.Is(err, fs.ErrNotExist) || c.opts.ImageBase == "" {
+		return data, err
+	}
+
+	data, err = c.fetchImage(ctx, titleKey, p)
+	if err != nil && ctx.Err() == nil {
+		c.opts.Logger.Printf("%s: %s", titleKey, err)
+		return nil, fs.ErrNotExist
+	}
+	return data, err
+}
+
+func (c *Converter) fetchImage(ctx context.Context, titleKey, p string) ([]byte, error) {
+	base := c.opts.ImageBase
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		return os.ReadFile(filepath.Join(base, titleKey, filepath.FromSlash(p)))
+	}
+
+	u := strings.TrimSuffix(base, "/") + "/" + titleKey + "/" + p
+	return c.opts.Downloader.Get(ctx, u)
+}
+
+// mapImages replaces each Image in the blocks by what f returns.
+func mapImages(blocks []Block, f func(Image) []Inline) []Block {
+	var content func([]Inline) []Inline
+	content = func(ins []Inline) []Inline {
+		var out []Inline
+		for _, in := range ins {
+			switch in := in.(type) {
+			case Image:
+				out = append(out, f(in)...)
+			case Span:
+				in.Content = content(in.Content)
+				out = append(out, in)
+			case Link:
+				in.Content = content(in.Content)
+				out = append(out, in)
+			case Note:
+				in.Content = content(in.Content)
+				out = append(out, in)
+			default:
+				out = append(out, in)
+			}
+		}
+		return out
+	}
+
+	for i, b := range blocks {
+		switch b := b.(type) {
+		case Paragraph:
+			b.Content = content(b.Content)
+			blocks[i] = b
+		case Heading:
+			b.Content = content(b.Content)
+			blocks[i] = b
+		case Verse:
+			for j := range b.Lines {
+				b.Lines[j] = content(b.Lines[j])
+			}
+		case Table:
+			for _, row := range b.Rows {
+				for j := range row.Cells {
+					row.Cells[j].Content = content(row.Cells[j].Content)
+				}
+			}
+		case List:
+			for j := range b.Items {
+				b.Items[j].Term = content(b.Items[j].Term)
+				b.Items[j].Blocks = mapImages(b.Items[j].Blocks, f)
+			}
+		case Preformatted:
+			b.Content = content(b.Content)
+			blocks[i] = b
+		case Div:
+			b.Blocks = mapImages(b.Blocks, f)
+			blocks[i] = b
+		}
+	}
+	return blocks
+}
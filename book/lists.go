@@ -0,0 +1,311 @@
+package book
+
+import (
+	"bufio"
+	_ "embed" // for go:embed
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var authors = map[string]Author{}
+
+// Author is a person in Project Runeberg, as listed in a.lst.
+type Author struct {
+	Key           string
+	Surname       string // Or primary sorting name, e.g. Birger Jarl
+	FirstName     string
+	Born          int      // 0 if unknown
+	Died          int      // 0 if unknown or still alive
+	Nationalities []string // ISO codes, e.g. se, or ?? for unknown
+	Notes         string   // Profession, pseudonyms, sources etc
+}
+
+// LookupAuthor returns the author with the key from the embedded
+// a.lst.
+func LookupAuthor(key string) (Author, bool) {
+	a, ok := authors[key]
+	return a, ok
+}
+
+func (a Author) FullName() string {
+	if a.FirstName == "" {
+		return a.Surname
+	}
+	return a.FirstName + " " + a.Surname
+}
+
+// SortName is the name as it should be sorted, e.g. "Lagerlöf, Selma".
+func (a Author) SortName() string {
+	if a.FirstName == "" {
+		return a.Surname
+	}
+	return a.Surname + ", " + a.FirstName
+}
+
+// NationalityNames resolves the nationality codes using the table
+// in the a.lst header.
+func (a Author) NationalityNames() []string {
+	var names []string
+	for _, code := range a.Nationalities {
+		if name, ok := nationalities[code]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, code)
+		}
+	}
+	return names
+}
+
+// Nationality codes to names, from the comments in a.lst
+var nationalities = map[string]string{"??": "Unknown"}
+
+// Expecting lines like: #	    176	de	= Germany
+var nationalityRE = regexp.MustCompile(`^#\s+(?:[0-9]+\s+)?([a-z]{2})\s+=\s+(.+)$`)
+
+// Contributor is a person credited for the book, resolved through
+// a.lst.
+type Contributor struct {
+	Key    string
+	Name   string // e.g. Selma Lagerlöf
+	FileAs string // e.g. Lagerlöf, Selma
+	Role   Role
+}
+
+// Contributors returns the people credited for the title that are
+// found in a.lst, authors first.
+func (t Title) Contributors() []Contributor {
+	var cs []Contributor
+	for _, role := range roles {
+		for _, key := range t.Keys(role) {
+			cs, _ = appendContributor(cs, key, role)
+		}
+	}
+	return cs
+}
+
+// Keys returns the keys of the people credited for the title in the
+// role.
+func (t Title) Keys(role Role) []string {
+	switch role {
+	case RoleAuthor:
+		return t.AuthorKeys
+	case RoleCoAuthor:
+		return t.CoAuthorKeys
+	case RoleTranslator:
+		return t.TranslatorKeys
+	}
+	return nil
+}
+
+// appendContributor appends the person with the key in a.lst, unless
+// already credited in that role. It reports false if the key is not
+// in a.lst.
+func appendContributor(cs []Contributor, key string, role Role) ([]Contributor, bool) {
+	a, ok := authors[key]
+	if !ok {
+		return cs, false
+	}
+
+	for _, c := range cs {
+		if c.Key == key && (c.Role == role || c.Role != RoleTranslator && role != RoleTranslator) {
+			return cs, true
+		}
+	}
+
+	return append(cs, Contributor{
+		Key:    key,
+		Name:   a.FullName(),
+		FileAs: a.SortName(),
+		Role:   role,
+	}), true
+}
+
+// Role is how a person is credited for a title.
+type Role int
+
+const (
+	RoleAuthor Role = iota
+	RoleCoAuthor
+	RoleTranslator
+)
+
+// All roles, authors first
+var roles = []Role{RoleAuthor, RoleCoAuthor, RoleTranslator}
+
+func (r Role) String() string {
+	switch r {
+	case RoleAuthor:
+		return "author"
+	case RoleCoAuthor:
+		return "co-author"
+	case RoleTranslator:
+		return "translator"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// Relator returns the MARC relator code of the role, as used in EPUB
+// metadata.
+func (r Role) Relator() string {
+	if r == RoleTranslator {
+		return "trl"
+	}
+	return "aut"
+}
+
+// Credit is a title that an author is credited for.
+type Credit struct {
+	Title Title
+	Role  Role
+}
+
+// CreditsOf returns all titles in t.lst that the author is credited
+// for, oldest first and those with unknown year last.
+func CreditsOf(authorKey string) []Credit {
+	var credits []Credit
+	for _, t := range titles {
+		switch {
+		case slices.Contains(t.AuthorKeys, authorKey):
+			credits = append(credits, Credit{Title: t, Role: RoleAuthor})
+		case slices.Contains(t.CoAuthorKeys, authorKey):
+			credits = append(credits, Credit{Title: t, Role: RoleCoAuthor})
+		case slices.Contains(t.TranslatorKeys, authorKey):
+			credits = append(credits, Credit{Title: t, Role: RoleTranslator})
+		}
+	}
+
+	sort.Slice(credits, func(i, j int) bool {
+		yi, yj := credits[i].Title.Year, credits[j].Title.Year
+		if yi != yj {
+			return yj == 0 || (yi != 0 && yi < yj)
+		}
+		return credits[i].Title.Key < credits[j].Title.Key
+	})
+
+	return credits
+}
+
+var titles = map[string]Title{}
+
+// Title is a work (edition) in Project Runeberg, as listed in t.lst.
+// Keys refer to authors in a.lst, languages are ISO639 codes.
+type Title struct {
+	Title             string
+	Key               string
+	AuthorKeys        []string
+	Languages         []string
+	Year              int // Year of first publishing, 0 if unknown
+	FinalYear         int // Set if the first publishing took more than one year
+	CoAuthorKeys      []string
+	TranslatorKeys    []string
+	OriginalLanguages []string // Set if this is a translation
+}
+
+// LookupTitle returns the title with the titlekey from the embedded
+// t.lst.
+func LookupTitle(key string) (Title, bool) {
+	t, ok := titles[key]
+	return t, ok
+}
+
+// IsTranslation tells whether this edition is translated from
+// another language.
+func (t Title) IsTranslation() bool {
+	if len(t.TranslatorKeys) > 0 {
+		return true
+	}
+	for _, lang := range t.OriginalLanguages {
+		if !slices.Contains(t.Languages, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+//go:embed a.lst
+var authorsData []byte
+
+//go:embed t.lst
+var titlesData []byte
+
+func init() {
+	authors = make(map[string]Author)
+	scanner := bufio.NewScanner(strings.NewReader(decodeISO8859_1(authorsData)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := nationalityRE.FindStringSubmatch(line); match != nil {
+			nationalities[match[1]] = strings.TrimSpace(match[2])
+			continue
+		}
+		if strings.HasPrefix(line, "#") || len(line) == 0 {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) != 7 {
+			fmt.Fprintf(os.Stderr, "Bad line in embedded authors data: %s\n", line)
+			os.Exit(1)
+		}
+		var nats []string
+		for _, code := range strings.Split(parts[4], ",") {
+			if code = strings.TrimSpace(code); code != "" {
+				nats = append(nats, code)
+			}
+		}
+		authors[parts[6]] = Author{
+			Key:           parts[6],
+			Surname:       parts[2],
+			FirstName:     parts[3],
+			Born:          parseYear(parts[0]),
+			Died:          parseYear(parts[1]),
+			Nationalities: nats,
+			Notes:         parts[5],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Scan failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	titles = make(map[string]Title)
+	scanner = bufio.NewScanner(strings.NewReader(decodeISO8859_1(titlesData)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || len(line) == 0 {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) < 9 {
+			fmt.Fprintf(os.Stderr, "Bad line in embedded titles data: %s\n", line)
+			os.Exit(1)
+		}
+		titles[parts[1]] = Title{
+			Title:             parts[0],
+			Key:               parts[1],
+			AuthorKeys:        strings.Fields(parts[2]),
+			Languages:         strings.Fields(parts[3]),
+			Year:              parseYear(parts[4]),
+			FinalYear:         parseYear(parts[5]),
+			CoAuthorKeys:      strings.Fields(parts[6]),
+			TranslatorKeys:    strings.Fields(parts[7]),
+			OriginalLanguages: strings.Fields(parts[8]),
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Scan failed: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseYear returns 0 for anything but a plain year, like "" or "16??".
+func parseYear(s string) int {
+	year, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return year
+}
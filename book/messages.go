@@ -0,0 +1,122 @@
+package book
+
+import (
+	"sort"
+	"strings"
+)
+
+// Text that the converter adds to books, by language. Swedish is the
+// fallback, being the language of most books in Project Runeberg.
+var messages = map[string]map[string]string{
+	"sv": {
+		"titlepage":  "Titelsida",
+		"source":     "Denna bok i EPUB-format har skapats från källfiler från Projekt Runeberg",
+		"sourcetext": "Denna text har skapats från källfiler från Projekt Runeberg",
+		"notes":      "Noter",
+		"backlink":   "Tillbaka till texten",
+		"volumes":    "Band",
+		"contents":   "Innehåll",
+		"image":      "bild",
+	},
+	"no": {
+		"titlepage":  "Tittelside",
+		"source":     "Denne boken i EPUB-format er laget fra kildefiler fra Projekt Runeberg",
+		"sourcetext": "Denne teksten er laget fra kildefiler fra Projekt Runeberg",
+		"notes":      "Noter",
+		"backlink":   "Tilbake til teksten",
+		"volumes":    "Bind",
+		"contents":   "Innhold",
+		"image":      "bilde",
+	},
+	"da": {
+		"titlepage":  "Titelblad",
+		"source":     "Denne bog i EPUB-format er lavet ud fra kildefiler fra Projekt Runeberg",
+		"sourcetext": "Denne tekst er lavet ud fra kildefiler fra Projekt Runeberg",
+		"notes":      "Noter",
+		"backlink":   "Tilbage til teksten",
+		"volumes":    "Bind",
+		"contents":   "Indhold",
+		"image":      "billede",
+	},
+	"fi": {
+		"titlepage":  "Nimiösivu",
+		"source":     "Tämä EPUB-kirja on luotu Projekt Runebergin lähdetiedostoista",
+		"sourcetext": "Tämä teksti on luotu Projekt Runebergin lähdetiedostoista",
+		"notes":      "Alaviitteet",
+		"backlink":   "Takaisin tekstiin",
+		"volumes":    "Osat",
+		"contents":   "Sisällys",
+		"image":      "kuva",
+	},
+	"en": {
+		"titlepage":  "Title page",
+		"source":     "This EPUB book was created from source files from Project Runeberg",
+		"sourcetext": "This text was created from source files from Project Runeberg",
+		"notes":      "Notes",
+		"backlink":   "Back to the text",
+		"volumes":    "Volumes",
+		"contents":   "Contents",
+		"image":      "image",
+	},
+	"de": {
+		"titlepage":  "Titelseite",
+		"source":     "Dieses EPUB-Buch wurde aus Quelldateien von Projekt Runeberg erstellt",
+		"sourcetext": "Dieser Text wurde aus Quelldateien von Projekt Runeberg erstellt",
+		"notes":      "Anmerkungen",
+		"backlink":   "Zurück zum Text",
+		"volumes":    "Bände",
+		"contents":   "Inhalt",
+		"image":      "Bild",
+	},
+	"is": {
+		"titlepage":  "Titilsíða",
+		"source":     "Þessi EPUB-bók var gerð úr frumskrám frá Projekt Runeberg",
+		"sourcetext": "Þessi texti var gerður úr frumskrám frá Projekt Runeberg",
+		"notes":      "Neðanmálsgreinar",
+		"backlink":   "Aftur í textann",
+		"volumes":    "Bindi",
+		"contents":   "Efnisyfirlit",
+		"image":      "mynd",
+	},
+}
+
+// Other codes for the languages of messages
+var messageAliases = map[string]string{
+	"nb": "no",
+	"nn": "no",
+}
+
+// MessageLanguages returns the codes of the languages that the text
+// added by the converter is available in.
+func MessageLanguages() []string {
+	var langs []string
+	for lang := range messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// messageLanguage returns the language of messages to use for lang,
+// which can be several codes like "da no sv" (as in Metadata) or a
+// tag like "nb-NO". The first code with messages is used, by exact
+// code, base language or alias. Or "" if none.
+func messageLanguage(lang string) string {
+	for _, code := range strings.Fields(strings.ToLower(lang)) {
+		code = strings.ReplaceAll(code, "_", "-")
+		base, _, _ := strings.Cut(code, "-")
+		for _, c := range []string{code, base, messageAliases[code], messageAliases[base]} {
+			if _, ok := messages[c]; ok {
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+func msg(lang, key string) string {
+	if m, ok := messages[messageLanguage(lang)][key]; ok {
+		return m
+	}
+	return messages["sv"][key]
+}
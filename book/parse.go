@@ -0,0 +1,351 @@
+package book
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// parseBlocks parses the HTML made by the process package into blocks.
+func parseBlocks(body string) ([]Block, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(body), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ParseFragment failed: %w", err)
+	}
+	return blocksOf(nodes), nil
+}
+
+func children(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// blocksOf turns nodes into blocks. Inline nodes between blocks are
+// put in paragraphs.
+func blocksOf(nodes []*html.Node) []Block {
+	var blocks []Block
+	var inline []*html.Node
+	flush := func() {
+		content := trimInlines(inlinesOf(inline))
+		if onlyPageBreaks(content) {
+			for _, in := range content {
+				blocks = append(blocks, in.(PageBreak))
+			}
+		} else if len(content) > 0 {
+			blocks = append(blocks, Paragraph{Content: content})
+		}
+		inline = nil
+	}
+
+	for _, n := range nodes {
+		if n.Type != html.ElementNode {
+			if n.Type == html.TextNode {
+				inline = append(inline, n)
+			}
+			continue
+		}
+
+		var b Block
+		switch n.Data {
+		case "p":
+			if content := trimInlines(inlinesOf(children(n))); onlyPageBreaks(content) {
+				flush()
+				for _, in := range content {
+					blocks = append(blocks, in.(PageBreak))
+				}
+				continue
+			} else if len(content) == 0 {
+				flush()
+				continue
+			}
+			b = paragraphOf(n)
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			b = Heading{Level: int(n.Data[1] - '0'), Content: trimInlines(inlinesOf(children(n)))}
+		case "table":
+			b = tableOf(n)
+		case "ul", "ol":
+			b = listOf(n)
+		case "dl":
+			b = descriptionListOf(n)
+		case "li", "dt", "dd":
+			// Outside of a list
+			flush()
+			blocks = append(blocks, blocksOf(children(n))...)
+			continue
+		case "pre":
+			b = Preformatted{Content: trimPreformatted(inlinesOf(children(n)))}
+		case "hr":
+			b = Rule{}
+		case "div", "center", "blockquote":
+			class := attr(n, "class")
+			if n.Data != "div" {
+				class = n.Data
+			}
+			b = Div{Class: class, Blocks: blocksOf(children(n))}
+		default:
+			inline = append(inline, n)
+			continue
+		}
+		flush()
+		blocks = append(blocks, b)
+	}
+	flush()
+
+	return blocks
+}
+
+func paragraphOf(n *html.Node) Block {
+	content := trimInlines(inlinesOf(children(n)))
+	class := attr(n, "class")
+	if class != "verse" {
+		return Paragraph{Class: class, Content: content}
+	}
+
+	var v Verse
+	var line []Inline
+	for _, in := range content {
+		if _, ok := in.(LineBreak); ok {
+			v.Lines = append(v.Lines, trimInlines(line))
+			line = nil
+			continue
+		}
+		line = append(line, in)
+	}
+	return Verse{Lines: append(v.Lines, trimInlines(line))}
+}
+
+// listOf returns the items of a ul or ol. Anything but li in it is
+// put in the item before.
+func listOf(n *html.Node) List {
+	l := List{Kind: Bulleted}
+	if n.Data == "ol" {
+		l.Kind = Numbered
+	}
+	for _, c := range children(n) {
+		switch {
+		case c.Type == html.ElementNode && c.Data == "li":
+			l.Items = append(l.Items, ListItem{Blocks: blocksOf(children(c))})
+		case len(l.Items) > 0:
+			item := &l.Items[len(l.Items)-1]
+			item.Blocks = append(item.Blocks, blocksOf([]*html.Node{c})...)
+		}
+	}
+	return l
+}
+
+// descriptionListOf returns the items of a dl, one for each dt with
+// the dd after it. Further dd go in the same item.
+func descriptionListOf(n *html.Node) List {
+	l := List{Kind: Described}
+	for _, c := range children(n) {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.Data {
+		case "dt":
+			l.Items = append(l.Items, ListItem{Term: trimInlines(inlinesOf(children(c)))})
+		case "dd":
+			if len(l.Items) == 0 {
+				l.Items = append(l.Items, ListItem{})
+			}
+			item := &l.Items[len(l.Items)-1]
+			item.Blocks = append(item.Blocks, blocksOf(children(c))...)
+		}
+	}
+	return l
+}
+
+// trimPreformatted removes line breaks at the end of the content,
+// keeping all other whitespace.
+func trimPreformatted(content []Inline) []Inline {
+	for len(content) > 0 {
+		t, ok := content[len(content)-1].(Text)
+		if !ok {
+			break
+		}
+		if t = Text(strings.TrimRight(string(t), "\n")); t != "" {
+			content[len(content)-1] = t
+			break
+		}
+		content = content[:len(content)-1]
+	}
+	return content
+}
+
+// alignOf returns the alignment from a class like "_c" (from Runeberg's
+// <td c>).
+func alignOf(n *html.Node) string {
+	switch attr(n, "class") {
+	case "_l":
+		return "left"
+	case "_c":
+		return "center"
+	case "_r":
+		return "right"
+	}
+	return ""
+}
+
+func tableOf(n *html.Node) Table {
+	t := Table{Align: alignOf(n)}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for _, c := range children(n) {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.Data != "tr" {
+				walk(c) // tbody etc
+				continue
+			}
+			var row Row
+			for _, td := range children(c) {
+				if td.Type != html.ElementNode || (td.Data != "td" && td.Data != "th") {
+					continue
+				}
+				colspan, _ := strconv.Atoi(attr(td, "colspan"))
+				row.Cells = append(row.Cells, Cell{
+					Colspan: colspan,
+					Align:   alignOf(td),
+					Content: trimInlines(inlinesOf(children(td))),
+				})
+			}
+			t.Rows = append(t.Rows, row)
+		}
+	}
+	walk(n)
+	return t
+}
+
+var spanStyles = map[string]Style{
+	"i":         Emphasis,
+	"em":        Emphasis,
+	"b":         Strong,
+	"strong":    Strong,
+	"u":         Underline,
+	"sup":       Sup,
+	"sub":       Sub,
+	"smallcaps": SmallCaps,
+	"spaced":    Spaced,
+	"big":       Big,
+}
+
+// inlinesOf turns nodes into inline content. Elements that do not
+// map to any are replaced by their content.
+func inlinesOf(nodes []*html.Node) []Inline {
+	var content []Inline
+	for _, n := range nodes {
+		switch n.Type {
+		case html.TextNode:
+			if len(content) > 0 {
+				if t, ok := content[len(content)-1].(Text); ok {
+					content[len(content)-1] = t + Text(n.Data)
+					continue
+				}
+			}
+			content = append(content, Text(n.Data))
+			continue
+		case html.ElementNode:
+		default:
+			continue
+		}
+
+		inner := inlinesOf(children(n))
+		switch n.Data {
+		case "br":
+			content = append(content, LineBreak{})
+		case "img":
+			content = append(content, Image{Src: attr(n, "src"), Alt: attr(n, "alt")})
+		case "a":
+			if href := attr(n, "href"); href != "" {
+				content = append(content, Link{URL: href, Content: inner})
+			} else {
+				content = append(content, inner...)
+			}
+		case "span":
+			class := attr(n, "class")
+			if class == "pagebreak" {
+				content = append(content, PageBreak{
+					Page:  strings.TrimPrefix(attr(n, "id"), "page-"),
+					Label: attr(n, "title"),
+				})
+			} else if class == "footnote" {
+				// The note reference goes right after the word
+				if len(content) > 0 {
+					if t, ok := content[len(content)-1].(Text); ok {
+						content[len(content)-1] = Text(strings.TrimRight(string(t), " \t\n"))
+					}
+				}
+				content = append(content, Note{Content: trimInlines(inner)})
+			} else if style, ok := spanStyles[class]; ok {
+				content = append(content, Span{Style: style, Content: inner})
+			} else {
+				content = append(content, inner...)
+			}
+		default:
+			if style, ok := spanStyles[n.Data]; ok {
+				content = append(content, Span{Style: style, Content: inner})
+			} else {
+				content = append(content, inner...)
+			}
+		}
+	}
+	return content
+}
+
+func onlyPageBreaks(content []Inline) bool {
+	for _, in := range content {
+		if _, ok := in.(PageBreak); !ok {
+			return false
+		}
+	}
+	return len(content) > 0
+}
+
+// trimInlines removes whitespace at the start and end of the content
+// (passing page breaks), and drops text if nothing is left.
+func trimInlines(content []Inline) []Inline {
+	for i := 0; i < len(content); i++ {
+		if t, ok := content[i].(Text); ok {
+			content[i] = Text(strings.TrimLeft(string(t), " \t\n"))
+		}
+		if _, ok := content[i].(PageBreak); !ok {
+			break
+		}
+	}
+	for i := len(content) - 1; i >= 0; i-- {
+		if t, ok := content[i].(Text); ok {
+			content[i] = Text(strings.TrimRight(string(t), " \t\n"))
+		}
+		if _, ok := content[i].(PageBreak); !ok {
+			break
+		}
+	}
+	var trimmed []Inline
+	for _, in := range content {
+		if t, ok := in.(Text); ok && t == "" {
+			continue
+		}
+		trimmed = append(trimmed, in)
+	}
+	return trimmed
+}
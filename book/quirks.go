@@ -0,0 +1,144 @@
+package book
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Quirks tells how to work around broken formatting of a book. They
+// are kept as profiles in JSON files named by titlekey, like
+// quirks/korkarlen.json:
+//
+//	{
+//	  "missing_bfl": true,
+//	  "chapter_titles": {"KAP. I": "Kapitel I"},
+//	  "chapter_levels": {"KAP. I": 1},
+//	  "skip_pages": ["0002"],
+//	  "substitutions": [{"from": "Ij", "to": "Ii"}]
+//	}
+type Quirks struct {
+	// The pages do not begin with a blank line when they begin a new
+	// paragraph.
+	MissingBFL bool `json:"missing_bfl,omitempty"`
+	// Replacements of chapter titles, by title in Articles.lst (or
+	// the h1 of the HTML-file).
+	ChapterTitles map[string]string `json:"chapter_titles,omitempty"`
+	// Levels of chapters in the table of contents, 0 being the top
+	// level, by title as for ChapterTitles. Others are worked out
+	// from Articles.lst, see nestChapters.
+	ChapterLevels map[string]int `json:"chapter_levels,omitempty"`
+	// Pages to leave out, like 0002 for Pages/0002.txt.
+	SkipPages []string `json:"skip_pages,omitempty"`
+	// Substitutions in the text of each page (or HTML-file), made
+	// before any other processing.
+	Substitutions []Substitution `json:"substitutions,omitempty"`
+}
+
+// Substitution replaces From with To. If Regexp is set, From is a
+// regular expression and To may refer to its groups like ${1}.
+type Substitution struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Regexp bool   `json:"regexp,omitempty"`
+
+	re *regexp.Regexp // From compiled, by LoadQuirks
+}
+
+func (s *Substitution) compile() error {
+	re, err := regexp.Compile(s.From)
+	if err != nil {
+		return fmt.Errorf("bad substitution regexp: %w", err)
+	}
+	s.re = re
+	return nil
+}
+
+func (s Substitution) apply(text string) (string, error) {
+	if !s.Regexp {
+		return strings.ReplaceAll(text, s.From, s.To), nil
+	}
+	if s.re == nil {
+		// Not from LoadQuirks
+		if err := s.compile(); err != nil {
+			return "", err
+		}
+	}
+	return s.re.ReplaceAllString(text, s.To), nil
+}
+
+func (q Quirks) substitute(text string) (string, error) {
+	var err error
+	for _, s := range q.Substitutions {
+		if text, err = s.apply(text); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+//go:embed quirks/*.json
+var quirksFS embed.FS
+
+// DefaultQuirks are the profiles that come with runepub, by titlekey.
+var DefaultQuirks map[string]Quirks
+
+func init() {
+	sub, err := fs.Sub(quirksFS, "quirks")
+	if err != nil {
+		panic(err)
+	}
+	if DefaultQuirks, err = LoadQuirks(sub); err != nil {
+		panic(err)
+	}
+}
+
+// LoadQuirks reads the profiles in the *.json files of fsys, by
+// titlekey (the basename of the file).
+func LoadQuirks(fsys fs.FS) (map[string]Quirks, error) {
+	fnames, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	quirks := make(map[string]Quirks)
+	for _, fname := range fnames {
+		data, err := fs.ReadFile(fsys, fname)
+		if err != nil {
+			return nil, fmt.Errorf("ReadFile failed: %w", err)
+		}
+
+		var q Quirks
+		if err = json.Unmarshal(data, &q); err != nil {
+			return nil, fmt.Errorf("%s: %w", fname, err)
+		}
+		for i := range q.Substitutions {
+			if !q.Substitutions[i].Regexp {
+				continue
+			}
+			if err = q.Substitutions[i].compile(); err != nil {
+				return nil, fmt.Errorf("%s: %w", fname, err)
+			}
+		}
+
+		quirks[strings.TrimSuffix(path.Base(fname), ".json")] = q
+	}
+
+	return quirks, nil
+}
+
+// MergeQuirks returns the profiles of all maps, later ones replacing
+// earlier ones for the same titlekey.
+func MergeQuirks(maps ...map[string]Quirks) map[string]Quirks {
+	merged := make(map[string]Quirks)
+	for _, m := range maps {
+		for k, q := range m {
+			merged[k] = q
+		}
+	}
+	return merged
+}
@@ -0,0 +1,127 @@
+package book
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PublicDomainRule decides whether a work is in the public domain,
+// using the years of death in a.lst. A work is free when every
+// contributor died more than Years years before the year of AsOf,
+// like the common life+70 rule. Anonymous works are free Years years
+// after their first publishing.
+type PublicDomainRule struct {
+	Years int
+	AsOf  time.Time // Zero means now
+	// Contributors without a year of death but with a year of birth
+	// are assumed dead this many years after birth. Zero means that
+	// they always block.
+	MaxLifespan int
+}
+
+var DefaultPublicDomainRule = PublicDomainRule{Years: 70, MaxLifespan: 100}
+
+// Blocker is a reason that a work is not in the public domain.
+type Blocker struct {
+	Contributor *Contributor // nil for anonymous works
+	Reason      string
+}
+
+func (bl Blocker) String() string {
+	if bl.Contributor == nil {
+		return bl.Reason
+	}
+	return fmt.Sprintf("%s (%s, %s): %s", bl.Contributor.Name, bl.Contributor.Key,
+		bl.Contributor.Role, bl.Reason)
+}
+
+// NotPublicDomainError tells which contributors keep a work from
+// being in the public domain.
+type NotPublicDomainError struct {
+	TitleKey string
+	Blockers []Blocker
+}
+
+func (e *NotPublicDomainError) Error() string {
+	var reasons []string
+	for _, bl := range e.Blockers {
+		reasons = append(reasons, bl.String())
+	}
+	return fmt.Sprintf("%s is not known to be in the public domain: %s", e.TitleKey,
+		strings.Join(reasons, "; "))
+}
+
+// Check returns a *NotPublicDomainError if the work by the
+// contributors, first published in year (0 if unknown), is not in the
+// public domain under the rule.
+func (r PublicDomainRule) Check(titleKey string, contributors []Contributor, year int) error {
+	asOf := r.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	// Protection lasts until the end of the last year
+	free := func(year int) bool { return year+r.Years < asOf.Year() }
+
+	var blockers []Blocker
+	for i := range contributors {
+		c := &contributors[i]
+		a, ok := authors[c.Key]
+		switch {
+		case !ok:
+			blockers = append(blockers, Blocker{c, "not found in a.lst"})
+		case a.Died != 0:
+			if !free(a.Died) {
+				blockers = append(blockers, Blocker{c, fmt.Sprintf("died %d, free from %d", a.Died, a.Died+r.Years+1)})
+			}
+		case a.Born != 0 && r.MaxLifespan != 0:
+			if !free(a.Born + r.MaxLifespan) {
+				blockers = append(blockers, Blocker{c, fmt.Sprintf("born %d, year of death unknown", a.Born)})
+			}
+		default:
+			blockers = append(blockers, Blocker{c, "years of birth and death unknown"})
+		}
+	}
+
+	if len(contributors) == 0 {
+		switch {
+		case year == 0:
+			blockers = append(blockers, Blocker{Reason: "anonymous work, year of publishing unknown"})
+		case !free(year):
+			blockers = append(blockers, Blocker{Reason: fmt.Sprintf("anonymous work published %d", year)})
+		}
+	}
+
+	if len(blockers) > 0 {
+		return &NotPublicDomainError{TitleKey: titleKey, Blockers: blockers}
+	}
+
+	return nil
+}
+
+// CheckPublicDomain checks the book's contributors against the rule,
+// see PublicDomainRule.Check. As with Title.CheckPublicDomain, those
+// with keys missing in a.lst block.
+func (b *Book) CheckPublicDomain(rule PublicDomainRule) error {
+	var year int
+	if b.Work != nil {
+		year = b.Work.Year
+	}
+	return rule.Check(b.TitleKey, append(slices.Clone(b.Contributors), b.UnknownContributors...), year)
+}
+
+// CheckPublicDomain checks the title's contributors in t.lst against
+// the rule, see PublicDomainRule.Check.
+func (t Title) CheckPublicDomain(rule PublicDomainRule) error {
+	cs := t.Contributors()
+	// Keys missing in a.lst must block rather than vanish
+	for _, role := range roles {
+		for _, key := range t.Keys(role) {
+			if _, ok := authors[key]; !ok {
+				cs = append(cs, Contributor{Key: key, Name: key, Role: role})
+			}
+		}
+	}
+	return rule.Check(t.Key, cs, t.Year)
+}
@@ -0,0 +1,175 @@
+package book
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Query is a search in the embedded catalog (t.lst and a.lst). Empty
+// fields match anything.
+type Query struct {
+	Words    []string // Words that must all occur in the title
+	Author   string   // Words that must all occur in an author's name
+	Language string   // ISO639 language code of the edition
+	FromYear int      // First year of publishing, inclusive
+	ToYear   int
+}
+
+// Hit is a title in the catalog matching a Query.
+type Hit struct {
+	Title   Title
+	Authors []string // Full names of the authors
+	Score   int
+}
+
+// Search looks up titles in the embedded catalog. Matching is
+// case-insensitive and ignores diacritics, so "karlek" finds
+// "kärlek". Hits are ordered with the best match first.
+func Search(q Query) []Hit {
+	words := fields(q.Words...)
+	authorWords := fields(q.Author)
+
+	var hits []Hit
+	for key, t := range titles {
+		if q.Language != "" && !slices.Contains(t.Languages, q.Language) {
+			continue
+		}
+
+		if q.FromYear != 0 || q.ToYear != 0 {
+			if t.Year == 0 {
+				continue
+			}
+			if (q.FromYear != 0 && t.Year < q.FromYear) || (q.ToYear != 0 && t.Year > q.ToYear) {
+				continue
+			}
+		}
+
+		score, ok := scoreTitle(key, t.Title, words)
+		if !ok {
+			continue
+		}
+
+		var names []string
+		for _, k := range t.AuthorKeys {
+			if a, ok := authors[k]; ok {
+				names = append(names, a.FullName())
+			}
+		}
+
+		if len(authorWords) > 0 {
+			s, ok := scoreAuthors(t.AuthorKeys, names, authorWords)
+			if !ok {
+				continue
+			}
+			score += s
+		}
+
+		hits = append(hits, Hit{
+			Title:   t,
+			Authors: names,
+			Score:   score,
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Title.Year != hits[j].Title.Year {
+			return hits[i].Title.Year < hits[j].Title.Year
+		}
+		return hits[i].Title.Key < hits[j].Title.Key
+	})
+
+	return hits
+}
+
+// scoreTitle requires every word to occur in the title (or be the
+// titlekey), rewarding whole words over prefixes over substrings.
+func scoreTitle(key, title string, words []string) (int, bool) {
+	folded := fold(title)
+	tokens := fields(title)
+
+	var score int
+	for _, w := range words {
+		switch {
+		case w == key:
+			score += 20
+		case slices.Contains(tokens, w):
+			score += 10
+		case anyHasPrefix(tokens, w):
+			score += 5
+		case strings.Contains(folded, w):
+			score += 2
+		default:
+			return 0, false
+		}
+	}
+
+	return score, true
+}
+
+func scoreAuthors(keys []string, names []string, words []string) (int, bool) {
+	var tokens []string
+	for _, name := range names {
+		tokens = append(tokens, fields(name)...)
+	}
+
+	var score int
+	for _, w := range words {
+		switch {
+		case slices.Contains(keys, w):
+			score += 6
+		case slices.Contains(tokens, w):
+			score += 3
+		case anyHasPrefix(tokens, w):
+			score += 1
+		default:
+			return 0, false
+		}
+	}
+
+	return score, true
+}
+
+// fields folds and splits the strings into words.
+func fields(ss ...string) []string {
+	var out []string
+	for _, s := range ss {
+		out = append(out, strings.FieldsFunc(fold(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return out
+}
+
+// Letters that do not decompose into a base letter and a combining mark
+var foldReplacer = strings.NewReplacer(
+	"æ", "ae", "ø", "o", "œ", "oe", "ß", "ss",
+	"þ", "th", "ð", "d", "ł", "l", "đ", "d",
+)
+
+// fold lowercases and strips diacritics.
+func fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return foldReplacer.Replace(out)
+}
+
+func anyHasPrefix(tokens []string, prefix string) bool {
+	for _, t := range tokens {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
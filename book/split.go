@@ -0,0 +1,126 @@
+package book
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Split tells how to split a book into parts, see Book.Split. Set
+// one of the fields.
+type Split struct {
+	// Ranges of chapters to make parts of, numbered from 1 after the
+	// title page, like {{1, 10}, {11, 20}}. There must be at least
+	// two, in order and not overlapping. Chapters outside of all
+	// ranges are left out.
+	Ranges [][2]int
+	// MaxSize in bytes of the text and images of a part. A chapter
+	// larger than this gets a part of its own.
+	MaxSize int
+	// Depth splits before each chapter less deep than this in the
+	// table of contents: 1 gives a part for each top-level entry.
+	Depth int
+}
+
+// Split splits the book into parts, each with the title page followed
+// by some of the chapters, and the images of those. The parts belong
+// to a collection named by the title of the book, and are numbered
+// from 1, also in their titlekeys, like "titlekey-01". A book that
+// does not need splitting by MaxSize or Depth is returned as it is.
+func (b *Book) Split(s Split) ([]*Book, error) {
+	if len(b.Chapters) < 2 && len(s.Ranges) == 0 {
+		return []*Book{b}, nil
+	}
+	var titlePage Chapter
+	chs := b.Chapters
+	if len(chs) > 0 {
+		titlePage, chs = chs[0], chs[1:]
+	}
+
+	var parts [][]Chapter
+	switch {
+	case len(s.Ranges) == 1:
+		return nil, fmt.Errorf("only one chapter range, want at least 2")
+	case len(s.Ranges) > 0:
+		for i, r := range s.Ranges {
+			if r[0] < 1 || r[1] < r[0] || r[1] > len(chs) {
+				return nil, fmt.Errorf("bad chapter range %d-%d, the book has chapters 1-%d", r[0], r[1], len(chs))
+			}
+			if i > 0 && r[0] <= s.Ranges[i-1][1] {
+				return nil, fmt.Errorf("chapter range %d-%d overlaps or comes before %d-%d",
+					r[0], r[1], s.Ranges[i-1][0], s.Ranges[i-1][1])
+			}
+			parts = append(parts, chs[r[0]-1:r[1]])
+		}
+	case s.MaxSize > 0:
+		var size int
+		for _, ch := range chs {
+			chSize := b.chapterSize(ch)
+			if len(parts) == 0 || size+chSize > s.MaxSize {
+				parts = append(parts, nil)
+				size = 0
+			}
+			parts[len(parts)-1] = append(parts[len(parts)-1], ch)
+			size += chSize
+		}
+	case s.Depth > 0:
+		for _, ch := range chs {
+			if len(parts) == 0 || ch.Level < s.Depth {
+				parts = append(parts, nil)
+			}
+			parts[len(parts)-1] = append(parts[len(parts)-1], ch)
+		}
+	}
+	if len(parts) < 2 {
+		return []*Book{b}, nil
+	}
+
+	var books []*Book
+	for i, part := range parts {
+		pb := *b
+		pb.Title = fmt.Sprintf("%s %d", b.Title, i+1)
+		pb.TitleKey = fmt.Sprintf("%s-%0*d", b.TitleKey, max(2, len(fmt.Sprint(len(parts)))), i+1)
+		pb.Collection = b.Title
+		pb.CollectionIndex = i + 1
+		pb.Chapters = append(Chapters{titlePage}, part...)
+		pb.Images = map[string][]byte{}
+		pb.MissingImages = nil
+
+		// The part begins at the top level of the table of contents
+		minLevel := slices.MinFunc(part, func(a, b Chapter) int { return a.Level - b.Level }).Level
+		for j := range part {
+			pb.Chapters[j+1].Level -= minLevel
+		}
+
+		for _, ch := range pb.Chapters {
+			for _, src := range imageSources(ch.Blocks) {
+				if data, ok := b.Images[src]; ok {
+					pb.Images[src] = data
+				}
+			}
+		}
+		books = append(books, &pb)
+	}
+	return books, nil
+}
+
+// chapterSize is about the size in bytes that the chapter takes up
+// in an EPUB, before compression.
+func (b *Book) chapterSize(ch Chapter) int {
+	size := len(renderXHTML(ch.Blocks, b.Language, nil))
+	for _, src := range imageSources(ch.Blocks) {
+		size += len(b.Images[src])
+	}
+	return size
+}
+
+// imageSources returns the sources of the images in the blocks.
+func imageSources(blocks []Block) []string {
+	var srcs []string
+	mapImages(blocks, func(img Image) []Inline {
+		if !slices.Contains(srcs, img.Src) {
+			srcs = append(srcs, img.Src)
+		}
+		return []Inline{img}
+	})
+	return srcs
+}
@@ -0,0 +1,424 @@
+package book
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteText writes the book as plain text using the default options,
+// see Converter.
+func (b *Book) WriteText(w io.Writer) error {
+	return NewConverter(Options{}).WriteText(context.Background(), b, w)
+}
+
+// WriteText writes the book as plain text (UTF-8), for search indexes
+// and the like. Headings are underlined, and notes are numbered and
+// put last in each chapter.
+func (c *Converter) WriteText(ctx context.Context, b *Book, w io.Writer) error {
+	return c.writeText(ctx, b, w, false, "")
+}
+
+// WriteMarkdown writes the book as Markdown using the default
+// options, see Converter.
+func (b *Book) WriteMarkdown(w io.Writer) error {
+	return NewConverter(Options{}).WriteMarkdown(context.Background(), b, w)
+}
+
+// WriteMarkdown writes the book as Markdown (CommonMark, with the
+// footnotes and pipe tables of GitHub and Pandoc, and the definition
+// lists of Pandoc), for static sites and the like. The metadata goes
+// in a YAML front matter. Small caps and letter-spaced text are
+// emphasized. Images refer to their paths in the book, relative to
+// the Markdown file, so the caller must write Book.Images there.
+func (c *Converter) WriteMarkdown(ctx context.Context, b *Book, w io.Writer) error {
+	return c.writeText(ctx, b, w, true, "")
+}
+
+// WriteMarkdownImages is like WriteMarkdown, but the images refer to
+// their paths under dir, relative to the Markdown file. A directory
+// for each book keeps the images of books written next to each other
+// apart.
+func (c *Converter) WriteMarkdownImages(ctx context.Context, b *Book, w io.Writer, dir string) error {
+	return c.writeText(ctx, b, w, true, dir)
+}
+
+func (c *Converter) writeText(ctx context.Context, b *Book, w io.Writer, markdown bool, imageDir string) error {
+	tw := &textWriter{lang: c.lang(b), markdown: markdown, imageDir: imageDir}
+	if markdown {
+		tw.writeFrontMatter(b)
+	} else {
+		tw.sb.WriteString(b.Title + "\n")
+		if b.Author != "" {
+			tw.sb.WriteString(b.Author + "\n")
+		}
+		tw.sb.WriteString("\n\n")
+	}
+
+	for _, ch := range b.Chapters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tw.level = ch.Level
+		for _, b := range ch.Blocks {
+			if text := tw.block(b); text != "" {
+				tw.sb.WriteString(text + "\n\n")
+			}
+		}
+		tw.writeNotes()
+		tw.sb.WriteString("\n")
+	}
+
+	if _, err := io.WriteString(w, strings.TrimRight(tw.sb.String(), "\n")+"\n"); err != nil {
+		return fmt.Errorf("WriteString failed: %w", err)
+	}
+	return nil
+}
+
+// textWriter renders blocks as plain text or Markdown, collecting the
+// notes of a chapter.
+type textWriter struct {
+	sb       strings.Builder
+	lang     string // Of the text the converter adds
+	markdown bool
+	imageDir string // Of the images in Markdown
+	level    int    // Of the chapter, deepening its headings
+	notes    [][]Inline
+	nNotes   int // Of earlier chapters, as notes are numbered through the book
+}
+
+func (w *textWriter) writeFrontMatter(b *Book) {
+	w.sb.WriteString("---\n")
+	for _, f := range [][2]string{
+		{"title", b.Title},
+		{"author", b.Author},
+		{"lang", b.mainLanguage()},
+		{"date", b.Year},
+		{"source", strings.Join(b.sources(), " ")},
+		{"collection", b.Collection},
+	} {
+		if f[1] != "" {
+			fmt.Fprintf(&w.sb, "%s: %s\n", f[0], strconv.Quote(f[1]))
+		}
+	}
+	if b.CollectionIndex > 0 {
+		fmt.Fprintf(&w.sb, "collection-index: %d\n", b.CollectionIndex)
+	}
+	w.sb.WriteString("---\n\n")
+}
+
+func (w *textWriter) writeNotes() {
+	if len(w.notes) == 0 {
+		return
+	}
+	// Notes may reference notes
+	for i := 0; i < len(w.notes); i++ {
+		n := w.nNotes + i + 1
+		if w.markdown {
+			fmt.Fprintf(&w.sb, "[^%d]: %s\n", n, w.inlines(w.notes[i]))
+		} else {
+			fmt.Fprintf(&w.sb, "[%d] %s\n", n, w.inlines(w.notes[i]))
+		}
+	}
+	w.sb.WriteString("\n")
+	w.nNotes += len(w.notes)
+	w.notes = nil
+}
+
+// block renders the block, or returns "" if there is nothing to it.
+func (w *textWriter) block(b Block) string {
+	switch b := b.(type) {
+	case Paragraph:
+		return w.paragraph(w.inlines(b.Content))
+	case Heading:
+		title := strings.ReplaceAll(w.inlines(b.Content), w.lineBreak(), " ")
+		level := min(b.Level+w.level, 6)
+		switch {
+		case title == "":
+			return ""
+		case w.markdown:
+			return strings.Repeat("#", level) + " " + title
+		case level == 1:
+			return title + "\n" + strings.Repeat("=", utf8.RuneCountInString(title))
+		default:
+			return title + "\n" + strings.Repeat("-", utf8.RuneCountInString(title))
+		}
+	case Verse:
+		var lines []string
+		for _, line := range b.Lines {
+			lines = append(lines, w.inlines(line))
+		}
+		return w.paragraph(strings.Join(lines, w.lineBreak()))
+	case Table:
+		return w.table(b)
+	case List:
+		return w.list(b)
+	case Preformatted:
+		text := PlainText(b.Content)
+		if w.markdown {
+			fence := "```"
+			for strings.Contains(text, fence) {
+				fence += "`"
+			}
+			return fence + "\n" + text + "\n" + fence
+		}
+		return text
+	case SourceNote:
+		return w.block(b.paragraph(w.lang, false))
+	case Rule:
+		return "* * *"
+	case Div:
+		var texts []string
+		for _, b := range b.Blocks {
+			if text := w.block(b); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		text := strings.Join(texts, "\n\n")
+		if w.markdown && b.Class == "blockquote" && text != "" {
+			text = "> " + strings.ReplaceAll(text, "\n", "\n> ")
+			text = strings.ReplaceAll(text, "\n> \n", "\n>\n")
+		}
+		return text
+	}
+	return ""
+}
+
+func (w *textWriter) paragraph(text string) string {
+	if w.markdown {
+		return escapeBlockStart(text)
+	}
+	return text
+}
+
+func (w *textWriter) lineBreak() string {
+	if w.markdown {
+		return "\\\n"
+	}
+	return "\n"
+}
+
+// list renders the list, with the items marked and indented. A
+// description has its term on a line before it, in Markdown as in a
+// Pandoc definition list.
+func (w *textWriter) list(l List) string {
+	var items []string
+	for i, item := range l.Items {
+		var texts []string
+		for _, b := range item.Blocks {
+			if text := w.block(b); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		text := strings.Join(texts, "\n\n")
+
+		var marker string
+		switch l.Kind {
+		case Bulleted:
+			marker = "- "
+		case Numbered:
+			marker = strconv.Itoa(i+1) + ". "
+		case Described:
+			marker = "    "
+			if w.markdown {
+				marker = ":   "
+			}
+		}
+		indent := strings.Repeat(" ", len(marker))
+		var lines []string
+		for j, line := range strings.Split(text, "\n") {
+			switch {
+			case j == 0:
+				line = strings.TrimRight(marker+line, " ")
+			case line != "":
+				line = indent + line
+			}
+			lines = append(lines, line)
+		}
+		text = strings.Join(lines, "\n")
+
+		if term := w.inlines(item.Term); term != "" {
+			term = strings.ReplaceAll(term, w.lineBreak(), " ")
+			if w.markdown {
+				term = escapeBlockStart(term)
+			}
+			text = term + "\n" + text
+		}
+		items = append(items, text)
+	}
+	if l.Kind == Described {
+		return strings.Join(items, "\n\n")
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders the table, in Markdown as a pipe table with the first
+// row as header.
+func (w *textWriter) table(t Table) string {
+	var cols int
+	for _, row := range t.Rows {
+		var n int
+		for _, cell := range row.Cells {
+			n += max(cell.Colspan, 1)
+		}
+		cols = max(cols, n)
+	}
+
+	var lines []string
+	for i, row := range t.Rows {
+		var cells, aligns []string
+		for _, cell := range row.Cells {
+			text := strings.ReplaceAll(w.inlines(cell.Content), "\n", " ")
+			if w.markdown {
+				text = strings.ReplaceAll(text, "|", `\|`)
+			}
+			align := cell.Align
+			if align == "" {
+				align = t.Align
+			}
+			cells = append(cells, text)
+			aligns = append(aligns, align)
+			for j := 1; j < cell.Colspan; j++ {
+				cells = append(cells, "")
+				aligns = append(aligns, align)
+			}
+		}
+		for len(cells) < cols {
+			cells = append(cells, "")
+			aligns = append(aligns, "")
+		}
+
+		if !w.markdown {
+			lines = append(lines, strings.TrimRight(strings.Join(cells, "\t"), "\t"))
+			continue
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			var seps []string
+			for _, align := range aligns {
+				switch align {
+				case "left":
+					seps = append(seps, ":---")
+				case "center":
+					seps = append(seps, ":---:")
+				case "right":
+					seps = append(seps, "---:")
+				default:
+					seps = append(seps, "---")
+				}
+			}
+			lines = append(lines, "|"+strings.Join(seps, "|")+"|")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inlines renders the content, collecting the notes.
+func (w *textWriter) inlines(content []Inline) string {
+	var sb strings.Builder
+	for _, in := range content {
+		switch in := in.(type) {
+		case Text:
+			if w.markdown {
+				sb.WriteString(escapeMarkdown(string(in)))
+			} else {
+				sb.WriteString(string(in))
+			}
+		case Span:
+			inner := w.inlines(in.Content)
+			if !w.markdown {
+				sb.WriteString(inner)
+				continue
+			}
+			var open, close string
+			switch in.Style {
+			case Emphasis, Underline, SmallCaps, Spaced:
+				open, close = "*", "*"
+			case Strong:
+				open, close = "**", "**"
+			case Sup:
+				open, close = "<sup>", "</sup>"
+			case Sub:
+				open, close = "<sub>", "</sub>"
+			}
+			// Emphasis may not begin or end with whitespace
+			trimmed := strings.TrimSpace(inner)
+			if trimmed == "" {
+				sb.WriteString(inner)
+				continue
+			}
+			start := inner[:strings.Index(inner, trimmed)]
+			sb.WriteString(start + open + trimmed + close + inner[len(start)+len(trimmed):])
+		case Link:
+			text := w.inlines(in.Content)
+			switch {
+			case w.markdown:
+				fmt.Fprintf(&sb, "[%s](<%s>)", text, in.URL)
+			case text == in.URL:
+				sb.WriteString(text)
+			default:
+				fmt.Fprintf(&sb, "%s (%s)", text, in.URL)
+			}
+		case LineBreak:
+			sb.WriteString(w.lineBreak())
+		case Note:
+			w.notes = append(w.notes, in.Content)
+			n := w.nNotes + len(w.notes)
+			if w.markdown {
+				fmt.Fprintf(&sb, "[^%d]", n)
+			} else {
+				fmt.Fprintf(&sb, "[%d]", n)
+			}
+		case Image:
+			switch {
+			case w.markdown:
+				fmt.Fprintf(&sb, "![%s](<%s>)", escapeMarkdown(in.Alt), path.Join(w.imageDir, in.Src))
+			case in.Alt != "":
+				sb.WriteString("[" + in.Alt + "]")
+			default:
+				// Not to leave a gap in the text
+				sb.WriteString("[" + msg(w.lang, "image") + "]")
+			}
+		}
+	}
+	return sb.String()
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+// escapeMarkdown escapes characters that would be taken as inline
+// markup.
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// escapeBlockStart escapes what would make a line of a paragraph
+// begin another kind of block, like a heading or list.
+func escapeBlockStart(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " ")
+		lines[i] = line[:len(line)-len(text)] + escapeLineStart(text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func escapeLineStart(s string) string {
+	for _, p := range []string{"#", ">", "-", "+", "=", "|"} {
+		if strings.HasPrefix(s, p) {
+			return `\` + s
+		}
+	}
+	// Like "1. " or "1) ", an ordered list
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i > 0 && (strings.HasPrefix(s[i:], ". ") || strings.HasPrefix(s[i:], ") ")) {
+		return s[:i] + `\` + s[i:]
+	}
+	return s
+}
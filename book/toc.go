@@ -0,0 +1,86 @@
+package book
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Nesting of the chapters in the table of contents, like chapters in
+// the parts of a novel.
+
+// Titles of parts, which the chapters after them belong to: an
+// ordinal and a word for part, or such a word and an Arabic or
+// upper-case Roman numeral ending the title or followed by "." or ":"
+// (so not "Boken i skogen")
+var partTitleRE = regexp.MustCompile(`(?i)^(?:` +
+	`(första|andra|tredje|fjärde|femte|sjätte|sjunde|åttonde|nionde|tionde|sista) (delen|boken|avdelningen)|` +
+	`(første|anden|andre|tredje|fjerde|femte|sjette|syvende|sjuende|ottende|åttende|niende|tiende|sidste|siste) (del|bog|bok)|` +
+	`(first|second|third|fourth|fifth|sixth|seventh|eighth|ninth|tenth|last) (part|book)|` +
+	`(erster|zweiter|dritter|vierter|fünfter|sechster|siebenter|siebter|achter|neunter|zehnter|letzter) (teil|band|buch)|` +
+	`(ensimmäinen|toinen|kolmas|neljäs|viides|kuudes|seitsemäs|kahdeksas|yhdeksäs|kymmenes|viimeinen) (osa|kirja)` +
+	`)\b|` +
+	`^(del|delen|bok|boken|bog|part|book|teil|buch|osa) ([0-9]+|(?-i:[IVXLC]+))(\s*$|[.:])`)
+
+// Indentation of titles in Articles.lst: spaces, tabs or &nbsp;
+var indentRE = regexp.MustCompile(`^(?: |\t|&nbsp;)*`)
+
+// cutIndent returns the title without indentation, and the width of
+// the indentation.
+func cutIndent(title string) (string, int) {
+	indent := indentRE.FindString(title)
+	width := strings.Count(indent, " ") + strings.Count(indent, "\t") + strings.Count(indent, "&nbsp;")
+	return title[len(indent):], width
+}
+
+var headingRE = regexp.MustCompile(`<h([1-6])>([^<]+)</h([1-6])>`)
+
+// chapterHeading returns the first of the highest-level headings in
+// the HTML, and its level, or "" if there is none.
+func chapterHeading(body string) (string, int) {
+	var title string
+	var level int
+	for _, m := range headingRE.FindAllStringSubmatch(body, -1) {
+		if m[1] != m[3] {
+			continue
+		}
+		if l := int(m[1][0] - '0'); title == "" || l < level {
+			title, level = m[2], l
+		}
+	}
+	return title, level
+}
+
+// nestChapters sets the levels of the chapters. The indents are those
+// of the chapters in Articles.lst, or the levels of their headings,
+// with more indented chapters going under less indented ones. If all
+// are the same, chapters after a part title go under it. Levels in
+// the quirks, by title in Articles.lst, go before either. A chapter
+// is never more than one level below the one before it.
+func nestChapters(chs []Chapter, titles []string, indents []int, quirks Quirks) {
+	distinct := slices.Clone(indents)
+	slices.Sort(distinct)
+	distinct = slices.Compact(distinct)
+
+	inPart := false
+	for i := range chs {
+		switch {
+		case len(distinct) > 1:
+			chs[i].Level, _ = slices.BinarySearch(distinct, indents[i])
+		case partTitleRE.MatchString(chs[i].Title):
+			chs[i].Level = 0
+			inPart = true
+		case inPart:
+			chs[i].Level = 1
+		}
+		if level, ok := quirks.ChapterLevels[titles[i]]; ok {
+			chs[i].Level = level
+		}
+
+		prev := -1
+		if i > 0 {
+			prev = chs[i-1].Level
+		}
+		chs[i].Level = max(0, min(chs[i].Level, prev+1))
+	}
+}
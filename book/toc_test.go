@@ -0,0 +1,56 @@
+package book
+
+import "testing"
+
+func TestPartTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{"Första delen", true},
+		{"Andra boken. Hemkomsten", true},
+		{"Second Part", true},
+		{"Del 1", true},
+		{"Del II", true},
+		{"Del II. Hemkomsten", true},
+		{"Bok III: Vintern", true},
+		{"Part 12", true},
+		{"DEL IV", true},
+		{"Boken i skogen", false},
+		{"Del i arven", false},
+		{"Del ii", false},
+		{"Del I av arven", false},
+		{"Del 2 av arven", false},
+		{"Delen av arvet", false},
+		{"Bokhandeln", false},
+		{"Kapitel I", false},
+	}
+	for _, tt := range tests {
+		if got := partTitleRE.MatchString(tt.title); got != tt.want {
+			t.Errorf("%q: got %t, want %t", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestChapterHeading(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		title string
+		level int
+	}{
+		{"one", "<h2>Kapitel</h2><p>Text</p>", "Kapitel", 2},
+		{"highest level", "<h2>Sub</h2><h1>Real</h1>", "Real", 1},
+		{"first of highest", "<h3>Sub</h3><h2>Real</h2><h2>Other</h2>", "Real", 2},
+		{"mismatched close", "<h1>Broken</h2><h2>Real</h2>", "Real", 2},
+		{"none", "<p>Text</p>", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, level := chapterHeading(tt.body)
+			if title != tt.title || level != tt.level {
+				t.Errorf("got %q, %d, want %q, %d", title, level, tt.title, tt.level)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package book
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+var alignClasses = map[string]string{
+	"left":   "_l",
+	"center": "_c",
+	"right":  "_r",
+}
+
+var styleTags = map[Style]string{
+	Emphasis:  "<i>",
+	Strong:    "<b>",
+	Underline: "<u>",
+	SmallCaps: `<span class="smallcaps">`,
+	Spaced:    `<span class="spaced">`,
+	Big:       `<span class="big">`,
+	Sup:       "<sup>",
+	Sub:       "<sub>",
+}
+
+// xhtmlWriter renders blocks as XHTML, collecting the notes.
+type xhtmlWriter struct {
+	sb       strings.Builder
+	lang     string            // Of the labels of notes
+	images   map[string]string // Paths of images in the output, by src
+	idPrefix string            // Of notes and page breaks, to tell chapters apart
+	epub     bool              // Whether it goes in an EPUB, see SourceNote
+	notes    [][]Inline
+}
+
+// renderXHTML renders the blocks as the content of an XHTML body.
+// Notes are numbered, and put last as EPUB 3 footnotes (which reading
+// systems may show as pop-ups), linking back to where they were
+// referenced. The src of images are replaced from the map, if there.
+// The blocks are taken to go in an EPUB.
+func renderXHTML(blocks []Block, lang string, images map[string]string) string {
+	w := &xhtmlWriter{lang: lang, images: images, epub: true}
+	return w.render(blocks)
+}
+
+func (w *xhtmlWriter) render(blocks []Block) string {
+	w.sb.WriteString("\n")
+	for _, b := range blocks {
+		w.writeBlock(b)
+		w.sb.WriteString("\n\n")
+	}
+	w.writeNotes()
+	return w.sb.String()
+}
+
+func (w *xhtmlWriter) writeNotes() {
+	if len(w.notes) == 0 {
+		return
+	}
+	fmt.Fprintf(&w.sb, "<section class=\"footnotes\" epub:type=\"footnotes\">\n<h2>%s</h2>\n",
+		html.EscapeString(msg(w.lang, "notes")))
+	// Notes may reference notes
+	for i := 0; i < len(w.notes); i++ {
+		n := i + 1
+		fmt.Fprintf(&w.sb, `<aside id="%snote%d" epub:type="footnote"><p>`, w.idPrefix, n)
+		fmt.Fprintf(&w.sb, `<a href="#%snoteref%d" title="%s">%d.</a> `, w.idPrefix, n, html.EscapeString(msg(w.lang, "backlink")), n)
+		w.writeInlines(w.notes[i])
+		w.sb.WriteString("</p></aside>\n")
+	}
+	w.sb.WriteString("</section>\n")
+}
+
+func classAttr(class string) string {
+	if class == "" {
+		return ""
+	}
+	return fmt.Sprintf(` class="%s"`, html.EscapeString(class))
+}
+
+func (w *xhtmlWriter) writeBlock(b Block) {
+	sb := &w.sb
+	switch b := b.(type) {
+	case Paragraph:
+		fmt.Fprintf(sb, "<p%s>", classAttr(b.Class))
+		w.writeInlines(b.Content)
+		sb.WriteString("</p>")
+	case Heading:
+		fmt.Fprintf(sb, "<h%d>", b.Level)
+		w.writeInlines(b.Content)
+		fmt.Fprintf(sb, "</h%d>", b.Level)
+	case Verse:
+		sb.WriteString(`<p class="verse">`)
+		for i, line := range b.Lines {
+			if i > 0 {
+				sb.WriteString("<br/>\n")
+			}
+			w.writeInlines(line)
+		}
+		sb.WriteString("</p>")
+	case Table:
+		fmt.Fprintf(sb, "<table%s>\n<tbody>", classAttr(alignClasses[b.Align]))
+		for _, row := range b.Rows {
+			sb.WriteString("<tr>")
+			for _, cell := range row.Cells {
+				sb.WriteString("<td")
+				if cell.Colspan > 1 {
+					fmt.Fprintf(sb, ` colspan="%d"`, cell.Colspan)
+				}
+				fmt.Fprintf(sb, "%s>", classAttr(alignClasses[cell.Align]))
+				w.writeInlines(cell.Content)
+				sb.WriteString("</td>")
+			}
+			sb.WriteString("</tr>\n")
+		}
+		sb.WriteString("</tbody></table>")
+	case List:
+		tag := map[ListKind]string{Bulleted: "ul", Numbered: "ol", Described: "dl"}[b.Kind]
+		fmt.Fprintf(sb, "<%s>\n", tag)
+		for _, item := range b.Items {
+			if b.Kind == Described {
+				if len(item.Term) > 0 {
+					sb.WriteString("<dt>")
+					w.writeInlines(item.Term)
+					sb.WriteString("</dt>\n")
+				}
+				sb.WriteString("<dd>")
+			} else {
+				sb.WriteString("<li>")
+			}
+			w.writeItemBlocks(item.Blocks)
+			if b.Kind == Described {
+				sb.WriteString("</dd>\n")
+			} else {
+				sb.WriteString("</li>\n")
+			}
+		}
+		fmt.Fprintf(sb, "</%s>", tag)
+	case Preformatted:
+		sb.WriteString("<pre>")
+		w.writeInlines(b.Content)
+		sb.WriteString("</pre>")
+	case Rule:
+		sb.WriteString("<hr/>")
+	case Div:
+		fmt.Fprintf(sb, "<div%s>\n", classAttr(b.Class))
+		for _, b := range b.Blocks {
+			w.writeBlock(b)
+			sb.WriteString("\n")
+		}
+		sb.WriteString("</div>")
+	case SourceNote:
+		w.writeBlock(b.paragraph(w.lang, w.epub))
+	case PageBreak:
+		w.writePageBreak(b)
+	}
+}
+
+// writeItemBlocks writes the blocks of a list item, a lone plain
+// paragraph without its p.
+func (w *xhtmlWriter) writeItemBlocks(blocks []Block) {
+	if len(blocks) == 1 {
+		if p, ok := blocks[0].(Paragraph); ok && p.Class == "" {
+			w.writeInlines(p.Content)
+			return
+		}
+	}
+	for i, b := range blocks {
+		if i > 0 {
+			w.sb.WriteString("\n")
+		}
+		w.writeBlock(b)
+	}
+}
+
+func (w *xhtmlWriter) writeInlines(content []Inline) {
+	sb := &w.sb
+	for _, in := range content {
+		switch in := in.(type) {
+		case Text:
+			sb.WriteString(html.EscapeString(string(in)))
+		case Span:
+			tag := styleTags[in.Style]
+			sb.WriteString(tag)
+			w.writeInlines(in.Content)
+			if strings.HasPrefix(tag, "<span") {
+				sb.WriteString("</span>")
+			} else {
+				sb.WriteString("</" + tag[1:])
+			}
+		case Link:
+			fmt.Fprintf(sb, `<a href="%s">`, html.EscapeString(in.URL))
+			w.writeInlines(in.Content)
+			sb.WriteString("</a>")
+		case LineBreak:
+			sb.WriteString("<br/>\n")
+		case Note:
+			w.notes = append(w.notes, in.Content)
+			n := len(w.notes)
+			fmt.Fprintf(sb, `<sup><a id="%[1]snoteref%[2]d" href="#%[1]snote%[2]d" epub:type="noteref">%[2]d</a></sup>`, w.idPrefix, n)
+		case Image:
+			src := in.Src
+			if p, ok := w.images[src]; ok {
+				src = p
+			}
+			fmt.Fprintf(sb, `<img src="%s" alt="%s"/>`, html.EscapeString(src), html.EscapeString(in.Alt))
+		case PageBreak:
+			w.writePageBreak(in)
+		}
+	}
+}
+
+func pageBreakID(pb PageBreak) string {
+	return "page-" + pb.Page
+}
+
+func (w *xhtmlWriter) writePageBreak(pb PageBreak) {
+	fmt.Fprintf(&w.sb, `<span id="%s" epub:type="pagebreak" role="doc-pagebreak" aria-label="%s"></span>`,
+		html.EscapeString(w.idPrefix+pageBreakID(pb)), html.EscapeString(pb.Label))
+}
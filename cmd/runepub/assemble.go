@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/quite/runepub/book"
+	"github.com/quite/runepub/internal/download"
+)
+
+// assembleManifest tells what books to assemble into one, read from a
+// JSON file like:
+//
+//	{
+//	  "title": "Trilogin",
+//	  "titlekey": "trilogin",
+//	  "volumes": [
+//	    {"titlekey": "bok1"},
+//	    {"titlekey": "bok2", "title": "Andra boken"},
+//	    {"path": "fixed/bok3"}
+//	  ]
+//	}
+//
+// A volume is downloaded by its titlekey, or read from a zip-file or
+// directory at path (relative to the manifest).
+type assembleManifest struct {
+	Title    string           `json:"title,omitempty"`
+	TitleKey string           `json:"titlekey,omitempty"`
+	Volumes  []assembleVolume `json:"volumes"`
+}
+
+type assembleVolume struct {
+	TitleKey string `json:"titlekey,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Title    string `json:"title,omitempty"` // Instead of that of the book
+}
+
+func readManifest(name string) (assembleManifest, error) {
+	var m assembleManifest
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return m, fmt.Errorf("ReadFile failed: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("Unmarshal failed: %w", err)
+	}
+	for i, vol := range m.Volumes {
+		if (vol.TitleKey == "") == (vol.Path == "") {
+			return m, fmt.Errorf("volume %d needs either titlekey or path", i+1)
+		}
+		if vol.Path != "" && !filepath.IsAbs(vol.Path) {
+			m.Volumes[i].Path = filepath.Join(filepath.Dir(name), vol.Path)
+		}
+	}
+	return m, nil
+}
+
+func assembleMain(args []string) {
+	var (
+		manifestFlag  string
+		titleFlag     string
+		keyFlag       string
+		outDirFlag    string
+		longNameFlag  bool
+		overwriteFlag bool
+		pdFlag        string
+		baseURLFlag   string
+		cacheFlag     string
+		refreshFlag   bool
+		formatFlag    string
+	)
+	descManifest := "Read the volumes, and title, from this JSON file"
+	descTitle := "Title of the book (default: the common start of the titles of\n" +
+		"                  the volumes)"
+	descKey := "Titlekey of the book, naming the output file (default: those of\n" +
+		"                  the volumes joined with +)"
+	descOutDir := "Write the output file in this directory"
+	fs := flag.NewFlagSet("assemble", flag.ExitOnError)
+	fs.StringVar(&manifestFlag, "manifest", "", descManifest)
+	fs.StringVar(&titleFlag, "title", "", descTitle)
+	fs.StringVar(&keyFlag, "key", "", descKey)
+	fs.StringVar(&outDirFlag, "o", ".", descOutDir)
+	fs.BoolVar(&longNameFlag, "l", false, descLongName)
+	fs.BoolVar(&overwriteFlag, "f", false, descOverwrite)
+	fs.StringVar(&pdFlag, "pd", "warn", descPD)
+	fs.StringVar(&baseURLFlag, "base-url", envOr("RUNEPUB_BASE_URL", download.DefaultBaseURL), descBaseURL)
+	fs.StringVar(&cacheFlag, "cache", defaultCacheDir(), descCache)
+	fs.BoolVar(&refreshFlag, "refresh", false, descRefresh)
+	fs.StringVar(&formatFlag, "format", "", descFormat)
+	getPDRule := pdRuleFlags(fs)
+	getConverter := converterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage:
+  runepub assemble [OPTIONS] TITLEKEY ...
+  runepub assemble [OPTIONS] -manifest FILE
+
+Downloads and converts the books, in order, and puts them together in
+one EPUB file. For works that Runeberg has split into volumes under
+several titlekeys, like a trilogy. Each volume gets an entry at the
+top level of the table of contents, with its chapters under it.
+
+A manifest file looks like:
+
+  {
+    "title": "Trilogin",
+    "titlekey": "trilogin",
+    "volumes": [
+      {"titlekey": "bok1"},
+      {"titlekey": "bok2", "title": "Andra boken"},
+      {"path": "fixed/bok3"}
+    ]
+  }
+
+where a volume with a path is read from that zip-file or directory.
+
+Options:
+  -manifest FILE  %s
+  -title TITLE    %s
+  -key KEY        %s
+  -o DIR          %s
+  -l              %s
+  -f              %s
+  -pd MODE        %s
+  -pd-years N     %s
+  -pd-date DATE   %s
+  -base-url URL   %s
+  -cache DIR      %s
+  -refresh        %s
+  -format FORMAT  %s
+%s`, descManifest, descTitle, descKey, descOutDir, descLongName, descOverwrite, descPD,
+			descPDYears, descPDDate, descBaseURL, descCache, descRefresh, descFormat, converterUsage())
+	}
+	fs.Parse(args)
+
+	var m assembleManifest
+	if manifestFlag != "" {
+		var err error
+		if m, err = readManifest(manifestFlag); err != nil {
+			failf("reading manifest failed: %s", err)
+		}
+	}
+	for _, key := range fs.Args() {
+		m.Volumes = append(m.Volumes, assembleVolume{TitleKey: key})
+	}
+	if titleFlag != "" {
+		m.Title = titleFlag
+	}
+	if keyFlag != "" {
+		m.TitleKey = keyFlag
+	}
+
+	if len(m.Volumes) < 2 {
+		fmt.Fprintf(os.Stderr, "Pass titlekeys of at least 2 books to assemble.\n\n")
+		fs.Usage()
+		os.Exit(2)
+	}
+	checkPDMode(pdFlag)
+	formatFlag = outputFormat(formatFlag, "")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cache := newCache(cacheFlag, baseURLFlag, 0)
+	conv := getConverter(cache.Downloader)
+	cfg := convertConfig{
+		outDir:    outDirFlag,
+		format:    formatFlag,
+		longName:  longNameFlag,
+		overwrite: overwriteFlag,
+		pdMode:    pdFlag,
+		pdRule:    getPDRule(),
+		logf:      msgf,
+	}
+
+	var vols []*book.Book
+	var keys []string
+	for _, vol := range m.Volumes {
+		src := vol.Path
+		if src == "" {
+			msgf("Getting %s ...\n", vol.TitleKey)
+			var err error
+			if src, _, err = cache.Get(ctx, vol.TitleKey, refreshFlag); err != nil {
+				failf("download failed: %s", err)
+			}
+		}
+
+		b, err := readBook(ctx, conv, src)
+		if err != nil {
+			failf("Convert failed: %s: %s", src, err)
+		}
+		if vol.Title != "" {
+			b.Title = vol.Title
+		}
+		msgf("Volume %d: %s (%s)\n", len(vols)+1, b.Title, b.TitleKey)
+		if err := checkBook(b, cfg); err != nil {
+			failf("%s", err)
+		}
+		vols = append(vols, b)
+		keys = append(keys, b.TitleKey)
+	}
+
+	if m.TitleKey == "" {
+		m.TitleKey = strings.Join(keys, "+")
+	}
+	b, err := conv.Assemble(vols, m.Title, m.TitleKey)
+	if err != nil {
+		failf("Assemble failed: %s", err)
+	}
+	msgf("Author: %s\nTitle: %s\nLang: %s\n", b.Author, b.Title, b.Language)
+
+	outname, err := writeBook(ctx, conv, b, cfg)
+	if err != nil {
+		failf("%s", err)
+	}
+	msgf("Wrote %s\n", outname)
+}
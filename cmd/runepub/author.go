@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/quite/runepub/book"
+)
+
+func authorMain(args []string) {
+	fs := flag.NewFlagSet("author", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage:
+  runepub author AUTHORKEY
+
+Prints what the embedded a.lst knows about the author, and all works
+in t.lst that the author is credited for.
+`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Pass the key of an author.\n\n")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	a, ok := book.LookupAuthor(fs.Arg(0))
+	if !ok {
+		failf("Unknown author key %q", fs.Arg(0))
+	}
+
+	msgf("Key: %s\n", a.Key)
+	msgf("Name: %s\n", a.FullName())
+	msgf("Born: %s\n", yearString(a.Born))
+	msgf("Died: %s\n", yearString(a.Died))
+	var nats []string
+	for i, name := range a.NationalityNames() {
+		nats = append(nats, fmt.Sprintf("%s (%s)", name, a.Nationalities[i]))
+	}
+	msgf("Nationality: %s\n", strings.Join(nats, ", "))
+	msgf("Notes: %s\n", a.Notes)
+
+	credits := book.CreditsOf(a.Key)
+	msgf("Works: %d\n", len(credits))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range credits {
+		role := ""
+		if c.Role != book.RoleAuthor {
+			role = c.Role.String()
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", c.Title.Key, yearString(c.Title.Year),
+			strings.Join(c.Title.Languages, " "), role, c.Title.Title)
+	}
+	tw.Flush()
+}
+
+func yearString(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quite/runepub/internal/download"
+)
+
+type batchResult struct {
+	titleKey string
+	outname  string
+	err      error
+}
+
+func batchMain(args []string) {
+	var (
+		jobsFlag      int
+		rateFlag      time.Duration
+		fileFlag      string
+		outDirFlag    string
+		longNameFlag  bool
+		overwriteFlag bool
+		pdFlag        string
+		baseURLFlag   string
+		cacheFlag     string
+		refreshFlag   bool
+		formatFlag    string
+	)
+	descJobs := "Convert this many books at the same time"
+	descRate := "Wait at least this long between requests to the server"
+	descFile := "Read titlekeys from this file, one per line, - for stdin"
+	descOutDir := "Write the output files in this directory"
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.IntVar(&jobsFlag, "j", 4, descJobs)
+	fs.DurationVar(&rateFlag, "rate", 2*time.Second, descRate)
+	fs.StringVar(&fileFlag, "file", "", descFile)
+	fs.StringVar(&outDirFlag, "o", ".", descOutDir)
+	fs.BoolVar(&longNameFlag, "l", false, descLongName)
+	fs.BoolVar(&overwriteFlag, "f", false, descOverwrite)
+	fs.StringVar(&pdFlag, "pd", "warn", descPD)
+	fs.StringVar(&baseURLFlag, "base-url", envOr("RUNEPUB_BASE_URL", download.DefaultBaseURL), descBaseURL)
+	fs.StringVar(&cacheFlag, "cache", defaultCacheDir(), descCache)
+	fs.BoolVar(&refreshFlag, "refresh", false, descRefresh)
+	fs.StringVar(&formatFlag, "format", "", descFormat)
+	getPDRule := pdRuleFlags(fs)
+	getConverter := converterFlags(fs)
+	getSplit := splitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage:
+  runepub batch [OPTIONS] [TITLEKEY ...]
+
+Downloads and converts many books, some at the same time. Titlekeys
+are given as arguments, in a file, or on stdin (by passing '-'). A
+book that fails does not stop the others; a summary is printed at the
+end.
+
+Options:
+  -j N            %s
+  -rate DUR       %s
+  -file FILE      %s
+  -o DIR          %s
+  -l              %s
+  -f              %s
+  -pd MODE        %s
+  -pd-years N     %s
+  -pd-date DATE   %s
+  -base-url URL   %s
+  -cache DIR      %s
+  -refresh        %s
+  -format FORMAT  %s
+%s%s`, descJobs, descRate, descFile, descOutDir, descLongName, descOverwrite, descPD,
+			descPDYears, descPDDate, descBaseURL, descCache, descRefresh, descFormat, converterUsage(), splitUsage())
+	}
+	fs.Parse(args)
+
+	var keys []string
+	for _, arg := range fs.Args() {
+		if arg == "-" {
+			keys = append(keys, readTitleKeys(os.Stdin)...)
+		} else {
+			keys = append(keys, arg)
+		}
+	}
+	if fileFlag == "-" {
+		keys = append(keys, readTitleKeys(os.Stdin)...)
+	} else if fileFlag != "" {
+		f, err := os.Open(fileFlag)
+		if err != nil {
+			failf("Open failed: %s", err)
+		}
+		keys = append(keys, readTitleKeys(f)...)
+		f.Close()
+	}
+
+	// A book converted twice at once would have both write its file
+	seen := map[string]bool{}
+	var unique []string
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	keys = unique
+
+	if len(keys) == 0 {
+		fmt.Fprintf(os.Stderr, "Pass titlekeys of books to convert.\n\n")
+		fs.Usage()
+		os.Exit(2)
+	}
+	if jobsFlag < 1 {
+		jobsFlag = 1
+	}
+	checkPDMode(pdFlag)
+	formatFlag = outputFormat(formatFlag, "")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cache := newCache(cacheFlag, baseURLFlag, rateFlag)
+	conv := getConverter(cache.Downloader)
+	rule := getPDRule()
+	split := getSplit()
+
+	results := make([]batchResult, len(keys))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobsFlag; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				key := keys[i]
+				logf := prefixedLogf(key)
+				cfg := convertConfig{
+					outDir:    outDirFlag,
+					format:    formatFlag,
+					longName:  longNameFlag,
+					overwrite: overwriteFlag,
+					pdMode:    pdFlag,
+					pdRule:    rule,
+					split:     split,
+					logf:      logf,
+				}
+				results[i] = batchResult{titleKey: key}
+				path, _, err := cache.Get(ctx, key, refreshFlag)
+				if err != nil {
+					results[i].err = fmt.Errorf("download failed: %w", err)
+					logf("%s\n", results[i].err)
+					continue
+				}
+				var outnames []string
+				outnames, results[i].err = convertBook(ctx, conv, path, cfg)
+				results[i].outname = strings.Join(outnames, " ")
+				if results[i].err != nil {
+					logf("%s\n", results[i].err)
+				}
+			}
+		}()
+	}
+
+	for i := range keys {
+		if ctx.Err() != nil {
+			results[i] = batchResult{titleKey: keys[i], err: ctx.Err()}
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed int
+	msgf("\n")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tFAILED\t%s\n", r.titleKey, r.err)
+		} else {
+			fmt.Fprintf(tw, "%s\tok\t%s\n", r.titleKey, r.outname)
+		}
+	}
+	tw.Flush()
+	msgf("%d converted, %d failed\n", len(results)-failed, failed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readTitleKeys reads one titlekey per line, skipping blank lines
+// and #-comments.
+func readTitleKeys(r io.Reader) []string {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			keys = append(keys, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		failf("Scan failed: %s", err)
+	}
+	return keys
+}
+
+// prefixedLogf returns a msgf that prefixes every line with the
+// titlekey, to tell the output of concurrent conversions apart.
+func prefixedLogf(titleKey string) func(format string, args ...interface{}) {
+	return func(format string, args ...interface{}) {
+		s := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+		msgf("%s: %s\n", titleKey, strings.ReplaceAll(s, "\n", "\n"+titleKey+": "))
+	}
+}
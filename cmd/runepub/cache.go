@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quite/runepub/internal/download"
+)
+
+const descCache = "Keep downloads in this directory (env RUNEPUB_CACHE)"
+
+func defaultCacheDir() string {
+	if dir := os.Getenv("RUNEPUB_CACHE"); dir != "" {
+		return dir
+	}
+	dir, err := download.DefaultCacheDir()
+	if err != nil {
+		// No cache dir; keep downloads in the current directory
+		return "."
+	}
+	return dir
+}
+
+func newCache(dir, baseURL string, interval time.Duration) *download.Cache {
+	return &download.Cache{
+		Dir: dir,
+		Downloader: &download.Downloader{
+			BaseURL:  baseURL,
+			Retries:  3,
+			Interval: interval,
+			Logf: func(format string, args ...interface{}) {
+				msgf(format+"\n", args...)
+			},
+		},
+	}
+}
+
+func cacheMain(args []string) {
+	var (
+		cacheFlag     string
+		olderThanFlag time.Duration
+		allFlag       bool
+	)
+	descOlderThan := "With prune: remove books downloaded longer ago than this, e.g. 720h"
+	descAll := "With prune: remove all books"
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	fs.StringVar(&cacheFlag, "cache", defaultCacheDir(), descCache)
+	fs.DurationVar(&olderThanFlag, "older-than", 0, descOlderThan)
+	fs.BoolVar(&allFlag, "all", false, descAll)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage:
+  runepub cache list [OPTIONS]
+  runepub cache prune [OPTIONS] [TITLEKEY ...]
+
+Lists or removes books in the download cache. Prune removes the books
+given by titlekey, or those selected by -older-than or -all.
+
+Options:
+  -cache DIR       %s
+  -older-than DUR  %s
+  -all             %s
+`, descCache, descOlderThan, descAll)
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args[1:])
+
+	cache := newCache(cacheFlag, "", 0)
+
+	switch args[0] {
+	case "list":
+		entries, err := cache.List()
+		if err != nil {
+			failf("List failed: %s", err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", e.TitleKey, e.Size,
+				e.Fetched.Local().Format(time.DateTime), e.Path)
+		}
+		tw.Flush()
+
+	case "prune":
+		var pruned []download.Entry
+		var err error
+		switch {
+		case fs.NArg() > 0:
+			for _, key := range fs.Args() {
+				var removed bool
+				if removed, err = cache.Remove(key); err != nil {
+					break
+				}
+				if !removed {
+					msgf("%s is not in the cache\n", key)
+					continue
+				}
+				pruned = append(pruned, download.Entry{TitleKey: key})
+			}
+		case allFlag:
+			pruned, err = cache.Prune(time.Now())
+		case olderThanFlag > 0:
+			pruned, err = cache.Prune(time.Now().Add(-olderThanFlag))
+		default:
+			failf("Pass titlekeys, -older-than or -all to prune")
+		}
+		for _, e := range pruned {
+			msgf("Removed %s\n", e.TitleKey)
+		}
+		if err != nil {
+			failf("Prune failed: %s", err)
+		}
+
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
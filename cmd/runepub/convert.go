@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/quite/runepub/book"
+	"github.com/quite/runepub/internal/download"
+)
+
+// convertConfig is how to convert and where to write a book.
+type convertConfig struct {
+	outDir    string
+	outFile   string // Instead of a name made by outputName
+	format    string // epub, txt, md or html
+	longName  bool
+	overwrite bool
+	pdMode    string // off, warn or refuse
+	pdRule    book.PublicDomainRule
+	split     book.Split
+	logf      func(format string, args ...interface{})
+}
+
+const (
+	descQuirks    = "Read extra quirks profiles (titlekey.json) from this directory"
+	descRepairBFL = "Insert paragraph breaks at page starts if the book seems to lack\n" +
+		"                  blank first lines: off, auto (when at least 10 pages seem to\n" +
+		"                  lack one) or always"
+	descKeepHyph = "Keep words split with a hyphen at line ends as they are"
+	descLang     = "Language of the text added to the book, like the title page\n" +
+		"                  (default: that of the book)"
+	descImages = "Get images missing in the zip-file from this site or directory,\n" +
+		"                  with the files of the book under its titlekey"
+	descCover = "Use this image (JPEG or PNG) as cover, instead of generating one;\n" +
+		"                  none for no cover"
+	descCoverLayout = "Layout of a generated cover: classic, band or frame (default:\n" +
+		"                  picked by the titlekey)"
+	descCoverScheme = "Colours of a generated cover: ink, forest, wine, sea, paper or\n" +
+		"                  slate (default: picked by the titlekey)"
+	descFormat = "Output format: epub, txt, md or html (default: by the extension\n" +
+		"                  of the output file, else epub)"
+	descSplitChapters = "Split the book into a file for each range of chapters, like\n" +
+		"                  1-10,11-20 (numbered from 1 after the title page; chapters\n" +
+		"                  outside of the ranges are left out)"
+	descSplitSize  = "Split the book into files of at most about this size, like 5M"
+	descSplitDepth = "Split the book before each chapter less deep than this in the\n" +
+		"                  table of contents (1 for each top-level entry)"
+)
+
+// converterUsage describes the flags of converterFlags, for usage
+// texts.
+func converterUsage() string {
+	return fmt.Sprintf(`  -quirks DIR     %s
+  -repair-bfl MODE
+                  %s
+  -keep-hyphens   %s
+  -lang LANG      %s
+  -images URL|DIR %s
+  -cover FILE     %s
+  -cover-layout LAYOUT
+                  %s
+  -cover-scheme SCHEME
+                  %s
+`, descQuirks, descRepairBFL, descKeepHyph, descLang, descImages, descCover,
+		descCoverLayout, descCoverScheme)
+}
+
+// converterFlags adds flags for how to convert books to fs. The
+// returned function makes the converter once fs is parsed.
+func converterFlags(fs *flag.FlagSet) func(*download.Downloader) *book.Converter {
+	var (
+		quirksDir   string
+		repairBFL   string
+		keepHyphens bool
+		lang        string
+		imageBase   string
+		cover       string
+		coverStyle  book.CoverStyle
+	)
+	fs.StringVar(&quirksDir, "quirks", "", descQuirks)
+	fs.StringVar(&repairBFL, "repair-bfl", "auto", descRepairBFL)
+	fs.BoolVar(&keepHyphens, "keep-hyphens", false, descKeepHyph)
+	fs.StringVar(&lang, "lang", "", descLang)
+	fs.StringVar(&imageBase, "images", "", descImages)
+	fs.StringVar(&cover, "cover", "", descCover)
+	fs.StringVar(&coverStyle.Layout, "cover-layout", "", descCoverLayout)
+	fs.StringVar(&coverStyle.Scheme, "cover-scheme", "", descCoverScheme)
+
+	return func(d *download.Downloader) *book.Converter {
+		opts := book.Options{
+			Quirks:      book.DefaultQuirks,
+			KeepHyphens: keepHyphens,
+			Language:    lang,
+			ImageBase:   imageBase,
+			Downloader:  d,
+			CoverStyle:  coverStyle,
+			Logger:      log.New(os.Stdout, "NOTE: ", 0),
+		}
+
+		switch cover {
+		case "":
+		case "none":
+			opts.NoCover = true
+		default:
+			data, err := os.ReadFile(cover)
+			if err != nil {
+				failf("ReadFile failed: %s", err)
+			}
+			opts.Cover = data
+		}
+
+		if quirksDir != "" {
+			if _, err := os.Stat(quirksDir); err != nil {
+				failf("Stat failed: %s", err)
+			}
+			dirQuirks, err := book.LoadQuirks(os.DirFS(quirksDir))
+			if err != nil {
+				failf("LoadQuirks failed: %s", err)
+			}
+			opts.Quirks = book.MergeQuirks(opts.Quirks, dirQuirks)
+		}
+
+		var ok bool
+		opts.RepairBFL, ok = map[string]book.RepairBFL{
+			"off":    book.RepairBFLOff,
+			"auto":   book.RepairBFLAuto,
+			"always": book.RepairBFLAlways,
+		}[repairBFL]
+		if !ok {
+			failf("Bad -repair-bfl mode %q, want off, auto or always", repairBFL)
+		}
+
+		if lang != "" && !slices.Contains(book.MessageLanguages(), lang) {
+			failf("Bad -lang %q, want one of: %s", lang, strings.Join(book.MessageLanguages(), " "))
+		}
+		if coverStyle.Layout != "" && !slices.Contains(book.CoverLayouts, coverStyle.Layout) {
+			failf("Bad -cover-layout %q, want one of: %s", coverStyle.Layout, strings.Join(book.CoverLayouts, " "))
+		}
+		if coverStyle.Scheme != "" && !slices.Contains(book.CoverSchemeNames(), coverStyle.Scheme) {
+			failf("Bad -cover-scheme %q, want one of: %s", coverStyle.Scheme, strings.Join(book.CoverSchemeNames(), " "))
+		}
+
+		return book.NewConverter(opts)
+	}
+}
+
+// Output formats, which are also their filename extensions
+var formats = []string{"epub", "txt", "md", "html"}
+
+// outputFormat checks the format, or picks it by the extension of the
+// output file.
+func outputFormat(format, outFile string) string {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(outFile), ".")
+		switch format {
+		case "markdown":
+			format = "md"
+		case "htm", "xhtml":
+			format = "html"
+		}
+		if !slices.Contains(formats, format) {
+			format = "epub"
+		}
+	}
+	if !slices.Contains(formats, format) {
+		failf("Bad -format %q, want one of: %s", format, strings.Join(formats, " "))
+	}
+	return format
+}
+
+// splitUsage describes the flags of splitFlags, for usage texts.
+func splitUsage() string {
+	return fmt.Sprintf(`  -split-chapters RANGES
+                  %s
+  -split-size SIZE
+                  %s
+  -split-depth N  %s
+`, descSplitChapters, descSplitSize, descSplitDepth)
+}
+
+// splitFlags adds flags for splitting books into parts to fs. The
+// returned function gets the split once fs is parsed.
+func splitFlags(fs *flag.FlagSet) func() book.Split {
+	var (
+		chapters string
+		size     string
+		depth    int
+	)
+	fs.StringVar(&chapters, "split-chapters", "", descSplitChapters)
+	fs.StringVar(&size, "split-size", "", descSplitSize)
+	fs.IntVar(&depth, "split-depth", 0, descSplitDepth)
+
+	return func() book.Split {
+		s := book.Split{Depth: depth}
+		var n int
+		if chapters != "" {
+			n++
+			for _, r := range strings.Split(chapters, ",") {
+				first, last, found := strings.Cut(strings.TrimSpace(r), "-")
+				if !found {
+					last = first
+				}
+				f, err1 := strconv.Atoi(first)
+				l, err2 := strconv.Atoi(last)
+				if err1 != nil || err2 != nil {
+					failf("Bad -split-chapters range %q", r)
+				}
+				s.Ranges = append(s.Ranges, [2]int{f, l})
+			}
+		}
+		if size != "" {
+			n++
+			num, unit := size, 1
+			switch size[len(size)-1] {
+			case 'k', 'K':
+				num, unit = size[:len(size)-1], 1<<10
+			case 'm', 'M':
+				num, unit = size[:len(size)-1], 1<<20
+			}
+			v, err := strconv.Atoi(num)
+			if err != nil || v <= 0 {
+				failf("Bad -split-size %q", size)
+			}
+			s.MaxSize = v * unit
+		}
+		if depth > 0 {
+			n++
+		}
+		if n > 1 {
+			failf("Pass only one of -split-chapters, -split-size and -split-depth")
+		}
+		return s
+	}
+}
+
+// readBook reads a book from a zip-file or directory.
+func readBook(ctx context.Context, conv *book.Converter, src string) (*book.Book, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, fmt.Errorf("Stat failed: %w", err)
+	}
+
+	if fi.IsDir() {
+		return conv.ConvertFS(ctx, os.DirFS(src))
+	}
+
+	zipData, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile failed: %w", err)
+	}
+	return conv.Convert(ctx, zipData)
+}
+
+// convertBook converts the book in src, returning the names of the
+// written files; more than one if split.
+func convertBook(ctx context.Context, conv *book.Converter, src string, cfg convertConfig) ([]string, error) {
+	b, err := readBook(ctx, conv, src)
+	if err != nil {
+		return nil, fmt.Errorf("Convert failed: %w", err)
+	}
+
+	cfg.logf("Author: %s\nTitle: %s\nLang: %s\n", b.Author, b.Title, b.Language)
+	cfg.logf("Chapters: %s\n", strings.Join(b.Chapters.Titles(), "; "))
+
+	if err := checkBook(b, cfg); err != nil {
+		return nil, err
+	}
+
+	parts, err := b.Split(cfg.split)
+	if err != nil {
+		return nil, fmt.Errorf("Split failed: %w", err)
+	}
+
+	var outnames []string
+	for _, part := range parts {
+		outname, err := writeBook(ctx, conv, part, cfg)
+		if err != nil {
+			return outnames, err
+		}
+		outnames = append(outnames, outname)
+	}
+	return outnames, nil
+}
+
+// checkBook checks the public domain status of the book, as
+// configured.
+func checkBook(b *book.Book, cfg convertConfig) error {
+	if cfg.pdMode != "off" {
+		if err := b.CheckPublicDomain(cfg.pdRule); err != nil {
+			if cfg.pdMode == "refuse" {
+				return fmt.Errorf("Refusing to convert: %w", err)
+			}
+			cfg.logf("WARNING: %s\n", err)
+		}
+	}
+	return nil
+}
+
+// writeBook writes the book in the configured format, returning the
+// name of the written file.
+func writeBook(ctx context.Context, conv *book.Converter, b *book.Book, cfg convertConfig) (string, error) {
+	outname := cfg.outFile
+	if outname == "" {
+		outname = filepath.Join(cfg.outDir, outputName(b, cfg.longName, cfg.format))
+	} else if b.CollectionIndex > 0 {
+		ext := filepath.Ext(outname)
+		outname = fmt.Sprintf("%s-%02d%s", strings.TrimSuffix(outname, ext), b.CollectionIndex, ext)
+	}
+
+	if !cfg.overwrite {
+		if _, err := os.Stat(outname); err == nil || !os.IsNotExist(err) {
+			return "", fmt.Errorf("Output file %q exists", outname)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outname), 0o755); err != nil {
+		return "", fmt.Errorf("MkdirAll failed: %w", err)
+	}
+	f, err := os.Create(outname)
+	if err != nil {
+		return "", fmt.Errorf("Create failed: %w", err)
+	}
+	defer f.Close()
+
+	switch cfg.format {
+	case "txt":
+		if err = conv.WriteText(ctx, b, f); err != nil {
+			return "", fmt.Errorf("WriteText failed: %w", err)
+		}
+	case "md":
+		// Like name.md with name_files/img/1.jpg, so that books
+		// written to the same directory keep their images apart
+		imageDir := strings.TrimSuffix(filepath.Base(outname), filepath.Ext(outname)) + "_files"
+		if err = conv.WriteMarkdownImages(ctx, b, f, imageDir); err != nil {
+			return "", fmt.Errorf("WriteMarkdown failed: %w", err)
+		}
+		if err = writeImages(b, filepath.Join(filepath.Dir(outname), imageDir), cfg.overwrite); err != nil {
+			return "", err
+		}
+	case "html":
+		if err = conv.WriteHTML(ctx, b, f); err != nil {
+			return "", fmt.Errorf("WriteHTML failed: %w", err)
+		}
+	default:
+		if err = conv.WriteEPUB(ctx, b, f); err != nil {
+			return "", fmt.Errorf("WriteEPUB failed: %w", err)
+		}
+	}
+
+	return outname, f.Close()
+}
+
+// writeImages writes the images of the book to their paths under dir.
+// An existing file is only replaced if overwrite, or if it is the same
+// image, as the parts of a split book share some.
+func writeImages(b *book.Book, dir string, overwrite bool) error {
+	for p, data := range b.Images {
+		if !filepath.IsLocal(p) {
+			return fmt.Errorf("Bad image path %q", p)
+		}
+		name := filepath.Join(dir, filepath.FromSlash(p))
+		if !overwrite {
+			if old, err := os.ReadFile(name); err == nil && !bytes.Equal(old, data) {
+				return fmt.Errorf("Image file %q exists", name)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return fmt.Errorf("MkdirAll failed: %w", err)
+		}
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			return fmt.Errorf("WriteFile failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func outputName(b *book.Book, long bool, format string) string {
+	if format == "" {
+		format = "epub"
+	}
+	if !long {
+		return fmt.Sprintf("%s.%s", b.TitleKey, format)
+	}
+
+	outname := fmt.Sprintf("%s - %s", b.Author, b.Title)
+	if b.Year != "" {
+		outname += fmt.Sprintf(" (%s)", b.Year)
+	}
+	outname += fmt.Sprintf(" [runeberg-%s].%s", b.TitleKey, format)
+	return outname
+}
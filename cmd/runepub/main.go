@@ -1,14 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"strings"
+	"os/signal"
 
-	"github.com/quite/runepub/internal/book"
+	"github.com/quite/runepub/internal/download"
 )
 
 func failf(format string, args ...interface{}) {
@@ -20,35 +19,110 @@ func msgf(format string, args ...interface{}) {
 	fmt.Printf(format, args...)
 }
 
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+const (
+	descDownload  = "Download the zip-file by its titlekey"
+	descLongName  = "Use long output filename, including author, title etc"
+	descOverwrite = "Overwrite existing output file"
+	descPD        = "Public domain check: off, warn or refuse (to convert)"
+	descBaseURL   = "Download from this site instead (env RUNEPUB_BASE_URL)"
+	descRefresh   = "Check if a cached download has changed, and fetch it again if so"
+	descOut       = "Write to this file (default: titlekey.epub, or .txt, .md or .html)"
+)
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "search":
+			searchMain(os.Args[2:])
+			return
+		case "author":
+			authorMain(os.Args[2:])
+			return
+		case "pd":
+			pdMain(os.Args[2:])
+			return
+		case "cache":
+			cacheMain(os.Args[2:])
+			return
+		case "batch":
+			batchMain(os.Args[2:])
+			return
+		case "assemble":
+			assembleMain(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		longNameFlag  bool
 		overwriteFlag bool
 		downloadFlag  bool
+		pdFlag        string
+		baseURLFlag   string
+		cacheFlag     string
+		refreshFlag   bool
+		outFlag       string
+		formatFlag    string
 	)
-	descDownload := "Download the zip-file by its titlekey"
-	descLongName := "Use long output filename, including author, title etc"
-	descOverwrite := "Overwrite existing output file"
 	flag.BoolVar(&downloadFlag, "d", false, descDownload)
 	flag.BoolVar(&longNameFlag, "l", false, descLongName)
 	flag.BoolVar(&overwriteFlag, "f", false, descOverwrite)
+	flag.StringVar(&pdFlag, "pd", "warn", descPD)
+	flag.StringVar(&baseURLFlag, "base-url", envOr("RUNEPUB_BASE_URL", download.DefaultBaseURL), descBaseURL)
+	flag.StringVar(&cacheFlag, "cache", defaultCacheDir(), descCache)
+	flag.BoolVar(&refreshFlag, "refresh", false, descRefresh)
+	flag.StringVar(&outFlag, "out", "", descOut)
+	flag.StringVar(&formatFlag, "format", "", descFormat)
+	getPDRule := pdRuleFlags(flag.CommandLine)
+	getConverter := converterFlags(flag.CommandLine)
+	getSplit := splitFlags(flag.CommandLine)
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage:
   runepub [OPTIONS] ZIP-FILE
+  runepub [OPTIONS] DIRECTORY
   runepub [OPTIONS] -d TITLEKEY
+  runepub search [OPTIONS] [WORDS ...]
+  runepub author AUTHORKEY
+  runepub pd [OPTIONS] TITLEKEY ...
+  runepub cache list|prune [OPTIONS]
+  runepub batch [OPTIONS] [TITLEKEY ...]
+  runepub assemble [OPTIONS] TITLEKEY ...
 
 This program tries to convert a book zip-file from https://runeberg.org
 into an EPUB file. It expects a typical 'titlekey-txt.zip' file as
-input. If the '-d' flag is used, it will instead try to download the
-file by its titlekey.
+input, or a directory with the files of one unpacked (for fixing
+errors in the text before converting). If the '-d' flag is used, it
+will instead try to download the file by its titlekey, keeping it in
+a cache directory for later runs. Use 'runepub search' to find the
+titlekey of a book.
 
-Default output filename: titlekey.epub
+Default output filename: titlekey.epub (titlekey-01.epub etc if split)
+
+Besides EPUB, the book can be written as plain text, Markdown or a
+single HTML file, by the '-format' flag or the extension of the '-out'
+file.
 
 Options:
   -d  %s
   -l  %s
   -f  %s
-`, descDownload, descLongName, descOverwrite)
+  -pd MODE        %s
+  -pd-years N     %s
+  -pd-date DATE   %s
+  -base-url URL   %s
+  -cache DIR      %s
+  -refresh        %s
+  -out FILE       %s
+  -format FORMAT  %s
+%s%s`, descDownload, descLongName, descOverwrite, descPD, descPDYears, descPDDate, descBaseURL,
+			descCache, descRefresh, descOut, descFormat, converterUsage(), splitUsage())
 	}
 	flag.Parse()
 
@@ -56,91 +130,51 @@ Options:
 		if downloadFlag {
 			fmt.Fprintf(os.Stderr, "Pass the titlekey of a book to download.\n\n")
 		} else {
-			fmt.Fprintf(os.Stderr, "Pass a book zip-file.\n\n")
+			fmt.Fprintf(os.Stderr, "Pass a book zip-file or directory.\n\n")
 		}
 		flag.Usage()
 		os.Exit(2)
 	}
 
+	checkPDMode(pdFlag)
+	formatFlag = outputFormat(formatFlag, outFlag)
+
 	src := flag.Args()[0]
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cache := newCache(cacheFlag, baseURLFlag, 0)
 	if downloadFlag {
-		if err := download(src); err != nil {
+		msgf("Getting %s ...\n", src)
+		path, fetched, err := cache.Get(ctx, src, refreshFlag)
+		if err != nil {
 			failf("download failed: %s", err)
 		}
-		src = fmt.Sprintf("%s-txt.zip", src)
-	}
-
-	zipData, err := os.ReadFile(src)
-	if err != nil {
-		failf("ReadFile failed: %s", err)
-	}
-
-	b, err := book.New(zipData)
-	if err != nil {
-		failf("book.New failed: %s", err)
-	}
-
-	msgf("Author: %s\nTitle: %s\nLang: %s\n", b.Author, b.Title, b.Language)
-	if b.MaybeMissingBFL {
-		msgf("NOTE: Book maybe missing blank first line for new paragraph!\n")
-	}
-	msgf("Chapters: %s\n", strings.Join(b.Chapters.Titles(), "; "))
-
-	outname := fmt.Sprintf("%s.epub", b.TitleKey)
-	if longNameFlag {
-		outname = fmt.Sprintf("%s - %s", b.Author, b.Title)
-		if b.Year != "" {
-			outname += fmt.Sprintf(" (%s)", b.Year)
+		if fetched {
+			msgf("Downloaded %s\n", path)
+		} else {
+			msgf("Using cached %s\n", path)
 		}
-		outname += fmt.Sprintf(" [runeberg-%s].epub", b.TitleKey)
+		src = path
 	}
 
-	if !overwriteFlag {
-		if _, err := os.Stat(outname); err == nil || !os.IsNotExist(err) {
-			failf("Output file %q exists", outname)
-		}
+	conv := getConverter(cache.Downloader)
+
+	outnames, err := convertBook(ctx, conv, src, convertConfig{
+		outFile:   outFlag,
+		format:    formatFlag,
+		longName:  longNameFlag,
+		overwrite: overwriteFlag,
+		pdMode:    pdFlag,
+		pdRule:    getPDRule(),
+		split:     getSplit(),
+		logf:      msgf,
+	})
+	for _, outname := range outnames {
+		msgf("Wrote %s\n", outname)
 	}
-
-	f, err := os.Create(outname)
 	if err != nil {
-		failf("Create failed: %s", err)
-	}
-	defer f.Close()
-
-	if err = b.WriteEPUB(f); err != nil {
-		failf("WriteEPUB failed: %s", err)
-	}
-	msgf("Wrote %s\n", outname)
-}
-
-func download(titleKey string) error {
-	zipFname := fmt.Sprintf("%s-txt.zip", titleKey)
-
-	if _, err := os.Stat(zipFname); err == nil {
-		msgf("Not downloading existing %s\n", zipFname)
-		return nil
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("unhandled Stat err: %w", err)
+		failf("%s", err)
 	}
-
-	msgf("Downloading %s ...\n", zipFname)
-	url := fmt.Sprintf("https://runeberg.org/download.pl?mode=txtzip&work=%s", titleKey)
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("Get failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	f, err := os.Create(zipFname)
-	if err != nil {
-		return fmt.Errorf("Create failed: %w", err)
-	}
-	defer f.Close()
-
-	if _, err = io.Copy(f, resp.Body); err != nil {
-		return fmt.Errorf("Copy failed: %w", err)
-	}
-
-	return nil
 }
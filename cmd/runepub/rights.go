@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/quite/runepub/book"
+)
+
+const (
+	descPDYears = "Protection lasts this many years after the death of the last contributor"
+	descPDDate  = "Check public domain status as of this date (YYYY-MM-DD), default today"
+)
+
+// pdRuleFlags adds flags for the public domain rule to fs. The
+// returned function gets the rule once fs is parsed.
+func pdRuleFlags(fs *flag.FlagSet) func() book.PublicDomainRule {
+	rule := book.DefaultPublicDomainRule
+	var dateFlag string
+	fs.IntVar(&rule.Years, "pd-years", rule.Years, descPDYears)
+	fs.StringVar(&dateFlag, "pd-date", "", descPDDate)
+
+	return func() book.PublicDomainRule {
+		if dateFlag != "" {
+			t, err := time.Parse(time.DateOnly, dateFlag)
+			if err != nil {
+				failf("Bad -pd-date: %s", err)
+			}
+			rule.AsOf = t
+		}
+		return rule
+	}
+}
+
+func checkPDMode(mode string) {
+	switch mode {
+	case "off", "warn", "refuse":
+	default:
+		failf("Bad -pd mode %q", mode)
+	}
+}
+
+func pdMain(args []string) {
+	fs := flag.NewFlagSet("pd", flag.ExitOnError)
+	getRule := pdRuleFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage:
+  runepub pd [OPTIONS] TITLEKEY ...
+
+Checks whether titles are in the public domain, using the years of
+death of their authors, co-authors and translators in the embedded
+a.lst. Exits non-zero if any title is not known to be free.
+
+Options:
+  -pd-years N     %s
+  -pd-date DATE   %s
+`, descPDYears, descPDDate)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Pass the titlekey of a book.\n\n")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	rule := getRule()
+	allFree := true
+	for _, key := range fs.Args() {
+		t, ok := book.LookupTitle(key)
+		if !ok {
+			msgf("%s: not found in t.lst\n", key)
+			allFree = false
+			continue
+		}
+		if err := t.CheckPublicDomain(rule); err != nil {
+			msgf("%s\n", err)
+			allFree = false
+			continue
+		}
+		msgf("%s: public domain\n", key)
+	}
+
+	if !allFree {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/quite/runepub/book"
+)
+
+func searchMain(args []string) {
+	var (
+		authorFlag string
+		langFlag   string
+		yearsFlag  string
+		limitFlag  int
+	)
+	descAuthor := "Only titles by an author whose name matches these words"
+	descLang := "Only titles in this language, e.g. sv"
+	descYears := "Only titles first published in YEAR or FROM-TO"
+	descLimit := "Show at most this many hits, 0 for all"
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	fs.StringVar(&authorFlag, "a", "", descAuthor)
+	fs.StringVar(&langFlag, "lang", "", descLang)
+	fs.StringVar(&yearsFlag, "y", "", descYears)
+	fs.IntVar(&limitFlag, "n", 20, descLimit)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage:
+  runepub search [OPTIONS] [WORDS ...]
+
+Searches the catalog of Project Runeberg titles embedded in this
+program and prints the titlekey of each hit, best match first. Words
+are matched against titles ignoring case and diacritics.
+
+Options:
+  -a AUTHOR  %s
+  -lang LANG %s
+  -y YEARS   %s
+  -n N       %s
+`, descAuthor, descLang, descYears, descLimit)
+	}
+	fs.Parse(args)
+
+	q := book.Query{
+		Words:    fs.Args(),
+		Author:   authorFlag,
+		Language: langFlag,
+	}
+
+	if yearsFlag != "" {
+		from, to, err := parseYears(yearsFlag)
+		if err != nil {
+			failf("Bad year range %q: %s", yearsFlag, err)
+		}
+		q.FromYear, q.ToYear = from, to
+	}
+
+	if len(q.Words) == 0 && q.Author == "" && q.Language == "" && yearsFlag == "" {
+		fmt.Fprintf(os.Stderr, "Pass something to search for.\n\n")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	hits := book.Search(q)
+	if len(hits) == 0 {
+		failf("No titles found")
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, hit := range hits {
+		if limitFlag > 0 && i == limitFlag {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", hit.Title.Key, yearString(hit.Title.Year),
+			strings.Join(hit.Title.Languages, " "), strings.Join(hit.Authors, ", "), hit.Title.Title)
+	}
+	tw.Flush()
+
+	if limitFlag > 0 && len(hits) > limitFlag {
+		msgf("(%d of %d hits shown)\n", limitFlag, len(hits))
+	}
+}
+
+// parseYears parses "1900" or "1900-1950", where either end of the
+// range may be left open.
+func parseYears(s string) (int, int, error) {
+	fromStr, toStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		toStr = fromStr
+	}
+
+	var from, to int
+	var err error
+	if fromStr != "" {
+		if from, err = strconv.Atoi(fromStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	if toStr != "" {
+		if to, err = strconv.Atoi(toStr); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return from, to, nil
+}
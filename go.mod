@@ -4,6 +4,7 @@ go 1.22.0
 
 require (
 	github.com/go-shiori/go-epub v1.2.1
+	golang.org/x/image v0.15.0
 	golang.org/x/net v0.22.0
 	golang.org/x/text v0.14.0
 )
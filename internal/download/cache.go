@@ -0,0 +1,197 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache keeps downloaded zip-files in a directory, by titlekey. Next
+// to each zip-file is a small JSON file with the validators of the
+// download, so it can be cheaply re-validated against the server.
+type Cache struct {
+	Dir        string
+	Downloader *Downloader
+}
+
+// Entry describes a cached zip-file.
+type Entry struct {
+	TitleKey   string     `json:"titlekey"`
+	URL        string     `json:"url"`
+	Validators Validators `json:"validators"`
+	Fetched    time.Time  `json:"fetched"`
+	Path       string     `json:"-"`
+	Size       int64      `json:"-"`
+}
+
+// DefaultCacheDir returns the runepub directory in the user's cache
+// directory, e.g. $XDG_CACHE_HOME/runepub.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "runepub"), nil
+}
+
+var titleKeyRE = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+
+const (
+	zipSuffix  = "-txt.zip"
+	metaSuffix = ".json"
+)
+
+// Get returns the path to the cached zip-file of the book, downloading
+// it if not cached. If refresh is set, a cached file is re-validated
+// and fetched again if changed on the server. The returned bool tells
+// whether anything was downloaded.
+func (c *Cache) Get(ctx context.Context, titleKey string, refresh bool) (string, bool, error) {
+	if !titleKeyRE.MatchString(titleKey) {
+		return "", false, fmt.Errorf("bad titlekey: %q", titleKey)
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return "", false, fmt.Errorf("MkdirAll failed: %w", err)
+	}
+
+	zipPath := filepath.Join(c.Dir, titleKey+zipSuffix)
+
+	entry, err := c.entry(titleKey)
+	switch {
+	case err == nil && !refresh:
+		return zipPath, false, nil
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist):
+		entry = Entry{TitleKey: titleKey}
+	default:
+		return "", false, err
+	}
+
+	d := c.Downloader
+	if d == nil {
+		d = &Downloader{}
+	}
+
+	v, modified, err := d.FetchIfModified(ctx, titleKey, zipPath, entry.Validators)
+	if err != nil {
+		return "", false, err
+	}
+
+	entry.URL = d.URL(titleKey)
+	entry.Validators = v
+	entry.Fetched = time.Now().UTC()
+	if err = c.writeEntry(entry); err != nil {
+		return "", false, err
+	}
+
+	return zipPath, modified, nil
+}
+
+func (c *Cache) entry(titleKey string) (Entry, error) {
+	var entry Entry
+
+	zipPath := filepath.Join(c.Dir, titleKey+zipSuffix)
+	fi, err := os.Stat(zipPath)
+	if err != nil {
+		return entry, err
+	}
+
+	// A zip-file without metadata, e.g. put there by hand, is still
+	// usable, it just cannot be re-validated
+	data, err := os.ReadFile(filepath.Join(c.Dir, titleKey+metaSuffix))
+	if err == nil {
+		if err = json.Unmarshal(data, &entry); err != nil {
+			return entry, fmt.Errorf("Unmarshal %s failed: %w", titleKey+metaSuffix, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return entry, fmt.Errorf("ReadFile failed: %w", err)
+	} else {
+		entry.Fetched = fi.ModTime().UTC()
+	}
+
+	entry.TitleKey = titleKey
+	entry.Path = zipPath
+	entry.Size = fi.Size()
+
+	return entry, nil
+}
+
+func (c *Cache) writeEntry(entry Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Marshal failed: %w", err)
+	}
+	return writeAtomic(filepath.Join(c.Dir, entry.TitleKey+metaSuffix), bytes.NewReader(data), nil)
+}
+
+// List returns the cached zip-files, by titlekey.
+func (c *Cache) List() ([]Entry, error) {
+	matches, err := filepath.Glob(filepath.Join(c.Dir, "*"+zipSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, m := range matches {
+		entry, err := c.entry(strings.TrimSuffix(filepath.Base(m), zipSuffix))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].TitleKey < entries[j].TitleKey
+	})
+
+	return entries, nil
+}
+
+// Remove removes the book from the cache, reporting whether it was
+// there.
+func (c *Cache) Remove(titleKey string) (bool, error) {
+	if !titleKeyRE.MatchString(titleKey) {
+		return false, fmt.Errorf("bad titlekey: %q", titleKey)
+	}
+
+	removed := false
+	for _, suffix := range []string{zipSuffix, metaSuffix} {
+		err := os.Remove(filepath.Join(c.Dir, titleKey+suffix))
+		switch {
+		case err == nil:
+			removed = true
+		case !errors.Is(err, os.ErrNotExist):
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Prune removes books fetched before the time, returning them.
+func (c *Cache) Prune(before time.Time) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []Entry
+	for _, entry := range entries {
+		if !entry.Fetched.Before(before) {
+			continue
+		}
+		if _, err = c.Remove(entry.TitleKey); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, entry)
+	}
+
+	return pruned, nil
+}
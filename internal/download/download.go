@@ -0,0 +1,313 @@
+// Package download fetches book zip-files, and other files like
+// images, from Project Runeberg.
+package download
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultBaseURL = "https://runeberg.org"
+
+// UserAgent is sent with every request.
+const UserAgent = "runepub (+https://github.com/quite/runepub)"
+
+// defaultClient gives up on a request after a while, but not before
+// the largest books have had time to come in on a slow line.
+var defaultClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Downloader fetches zip-files. The zero value is usable. It is safe
+// for concurrent use, but must not be copied.
+type Downloader struct {
+	// BaseURL of the site, e.g. a local stand-in for testing. Empty
+	// means DefaultBaseURL.
+	BaseURL string
+	// Client nil means one with a timeout of 5 minutes.
+	Client *http.Client
+	// Retries after a failed attempt, with Backoff before the first
+	// retry, doubling for each one.
+	Retries int
+	Backoff time.Duration
+	// Interval is the least time between requests, to be polite to
+	// the server when fetching many books.
+	Interval time.Duration
+	// Logf, if set, gets told about retries.
+	Logf func(format string, args ...interface{})
+
+	mu   sync.Mutex
+	next time.Time // When the next request may be made
+}
+
+// StatusError is returned when the server does not respond with 200 OK.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// ErrNotZip is returned when the response is not a zip-file, like an
+// HTML error page.
+var ErrNotZip = errors.New("response is not a zip-file")
+
+// Validators identify a version of a fetched file, for conditional
+// requests.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// URL returns where the zip-file of the book is.
+func (d *Downloader) URL(titleKey string) string {
+	base := d.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return fmt.Sprintf("%s/download.pl?mode=txtzip&work=%s", strings.TrimSuffix(base, "/"),
+		url.QueryEscape(titleKey))
+}
+
+// Fetch downloads the zip-file of the book to path. The file is
+// written in place only when complete and verified to be a zip-file,
+// so a failed download never leaves a broken file behind.
+func (d *Downloader) Fetch(ctx context.Context, titleKey, path string) error {
+	_, _, err := d.FetchIfModified(ctx, titleKey, path, Validators{})
+	return err
+}
+
+// FetchIfModified is like Fetch, but leaves path alone and reports
+// false if the server says that the file is unchanged since the
+// version identified by v. The validators of the fetched version are
+// returned.
+func (d *Downloader) FetchIfModified(ctx context.Context, titleKey, path string, v Validators) (Validators, bool, error) {
+	var (
+		newV     Validators
+		modified bool
+	)
+	err := d.retry(ctx, func() error {
+		var err error
+		newV, modified, err = d.fetch(ctx, d.URL(titleKey), path, v)
+		return err
+	})
+	if err != nil {
+		return Validators{}, false, err
+	}
+	return newV, modified, nil
+}
+
+// Get fetches any file, like an image, into memory. It is paced and
+// retried like the zip-files.
+func (d *Downloader) Get(ctx context.Context, url string) ([]byte, error) {
+	var data []byte
+	err := d.retry(ctx, func() error {
+		var err error
+		data, err = d.get(ctx, url)
+		return err
+	})
+	return data, err
+}
+
+// retry calls f until it succeeds, or the retries are used up.
+func (d *Downloader) retry(ctx context.Context, f func() error) error {
+	backoff := d.Backoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if attempt >= d.Retries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		d.logf("%s, retrying in %s", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// retryable tells whether trying again might help.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	// The server answers, just not with the book
+	return !errors.Is(err, ErrNotZip)
+}
+
+// wait blocks until a request may be made, reserving the slot.
+func (d *Downloader) wait(ctx context.Context) error {
+	if d.Interval == 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	now := time.Now()
+	at := d.next
+	if at.Before(now) {
+		at = now
+	}
+	d.next = at.Add(d.Interval)
+	d.mu.Unlock()
+
+	select {
+	case <-time.After(at.Sub(now)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newRequest waits for its turn, and makes a GET request for url.
+func (d *Downloader) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	if err := d.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("NewRequest failed: %w", err)
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	return req, nil
+}
+
+func (d *Downloader) client() *http.Client {
+	if d.Client == nil {
+		return defaultClient
+	}
+	return d.Client
+}
+
+func (d *Downloader) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := d.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Get failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ReadAll failed: %w", err)
+	}
+	return data, nil
+}
+
+func (d *Downloader) fetch(ctx context.Context, url, path string, v Validators) (Validators, bool, error) {
+	req, err := d.newRequest(ctx, url)
+	if err != nil {
+		return v, false, err
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return v, false, fmt.Errorf("Get failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && v != (Validators{}) {
+		return v, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return v, false, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	if err = writeAtomic(path, resp.Body, verifyZip); err != nil {
+		return v, false, err
+	}
+
+	return Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, true, nil
+}
+
+// writeAtomic writes r to a temporary file next to path, and renames
+// it to path if verify (when set) accepts it.
+func writeAtomic(path string, r io.Reader, verify func(string) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("CreateTemp failed: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("Copy failed: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("Sync failed: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("Close failed: %w", err)
+	}
+
+	if verify != nil {
+		if err = verify(tmp); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("Rename failed: %w", err)
+	}
+
+	return nil
+}
+
+func verifyZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNotZip, err)
+	}
+	defer r.Close()
+
+	if len(r.File) == 0 {
+		return fmt.Errorf("%w: no files in it", ErrNotZip)
+	}
+
+	return nil
+}
+
+func (d *Downloader) logf(format string, args ...interface{}) {
+	if d.Logf != nil {
+		d.Logf(format, args...)
+	}
+}
@@ -0,0 +1,209 @@
+package process
+
+import (
+	"bufio"
+	"embed"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed words/*.txt
+var wordsFS embed.FS
+
+// Conjunctions after which a hyphen at the end of a line is kept, as
+// in "syster- och brödraskap".
+var conjunctions = map[string][]string{
+	"sv": {"och", "eller", "samt"},
+	"da": {"og", "eller"},
+	"no": {"og", "eller"},
+	"en": {"and", "or"},
+	"de": {"und", "oder"},
+	"fi": {"ja", "tai"},
+	"is": {"og", "eða"},
+}
+
+// Vocabulary knows the words of a language from a list of common
+// ones, and counts the words, and words with hyphens in them, seen in
+// a text (besides those split at line ends).
+type Vocabulary struct {
+	lang  string
+	list  map[string]bool
+	words map[string]int
+}
+
+// NewVocabulary returns a vocabulary for the language, like "sv" or
+// "en-GB", with the word list of the language if there is one.
+func NewVocabulary(lang string) *Vocabulary {
+	lang, _, _ = strings.Cut(strings.ToLower(lang), "-")
+	v := &Vocabulary{lang: lang, list: map[string]bool{}, words: map[string]int{}}
+
+	data, err := wordsFS.ReadFile("words/" + lang + ".txt")
+	if err != nil {
+		// No list for this language
+		return v
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" && !strings.HasPrefix(w, "#") {
+			v.list[w] = true
+		}
+	}
+	return v
+}
+
+// Add counts the words in the text.
+func (v *Vocabulary) Add(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			w := strings.TrimFunc(f, func(r rune) bool { return !unicode.IsLetter(r) && r != '-' })
+			if i == len(fields)-1 && strings.HasSuffix(w, "-") {
+				// Split at the line end, that's what we're after
+				continue
+			}
+			w = strings.Trim(w, "-")
+			if w != "" && !strings.ContainsFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && r != '-' }) {
+				v.words[strings.ToLower(w)]++
+			}
+		}
+	}
+}
+
+func (v *Vocabulary) count(word string) int {
+	return v.words[strings.ToLower(word)]
+}
+
+func (v *Vocabulary) listed(word string) bool {
+	return v.list[strings.ToLower(word)]
+}
+
+// Hyphenation is a word split with a hyphen at a line end, where it
+// was not clear whether the hyphen belongs to the word.
+type Hyphenation struct {
+	Split  string // Like "för-sta"
+	Result string // What we made of it
+	Reason string
+}
+
+// Dehyphenate rejoins words split with a hyphen at the end of a line,
+// moving the rest of the word up to the previous line. The hyphen is
+// dropped unless it seems to belong to the word: when the rest begins
+// with an uppercase letter (and the first part is not all uppercase),
+// or only the hyphenated word is in the word list of the language.
+// If the list does not tell, the hyphen is kept if the hyphenated
+// word is more common in the text than the joined one. A word found
+// neither way is joined. A hyphen followed by a conjunction is left as
+// it is, on its line. A range of numbers, like "12-14", is joined
+// keeping the hyphen. Cases that could go either way are returned.
+func Dehyphenate(text string, v *Vocabulary) (string, []Hyphenation) {
+	var ambiguous []Hyphenation
+
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines)-1; i++ {
+		first := splitWordEnd(lines[i])
+		number := first == "" && splitNumberEnd(lines[i])
+		if first == "" && !number {
+			continue
+		}
+
+		// The word may go on after a page break
+		j := i + 1
+		for j < len(lines)-1 && isPageBreak(lines[j]) {
+			j++
+		}
+
+		token, remaining, _ := strings.Cut(lines[j], " ")
+		var keep bool
+		if number {
+			if r, _ := utf8.DecodeRuneInString(token); !unicode.IsDigit(r) {
+				continue
+			}
+			keep = true
+		} else {
+			rest := strings.TrimRightFunc(token, func(r rune) bool { return !unicode.IsLetter(r) })
+			if rest == "" || strings.ContainsFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) && r != '-' }) {
+				continue
+			}
+			if isConjunction(v.lang, rest) {
+				continue
+			}
+			var h *Hyphenation
+			if keep, h = v.keepHyphen(first, rest); h != nil {
+				ambiguous = append(ambiguous, *h)
+			}
+		}
+
+		if !keep {
+			lines[i] = strings.TrimSuffix(lines[i], "-")
+		}
+		lines[i] += token
+		lines[j] = strings.TrimLeft(remaining, " ")
+		if lines[j] == "" {
+			lines = append(lines[:j], lines[j+1:]...)
+		}
+		// The moved token might itself end with a split word
+		i--
+	}
+
+	return strings.Join(lines, "\n"), ambiguous
+}
+
+// keepHyphen tells whether the hyphen between the parts of a split
+// word belongs to it, see Dehyphenate. If that is not clear, the case
+// is returned too.
+func (v *Vocabulary) keepHyphen(first, rest string) (bool, *Hyphenation) {
+	joined := first + rest
+	hyphenated := first + "-" + rest
+	r, _ := utf8.DecodeRuneInString(rest)
+	lj, lh := v.listed(joined), v.listed(hyphenated)
+	switch nj, nh := v.count(joined), v.count(hyphenated); {
+	case unicode.IsUpper(r) && strings.ToUpper(first) != first:
+		return true, nil
+	case lj && !lh:
+		return false, nil
+	case lh && !lj:
+		return true, nil
+	case nj > 0 && nh == 0:
+		return false, nil
+	case nh > 0 && nj == 0:
+		return true, nil
+	case nj == 0 && nh == 0:
+		return false, &Hyphenation{Split: hyphenated, Result: joined, Reason: "neither word known"}
+	default:
+		keep := nh > nj
+		result := joined
+		if keep {
+			result = hyphenated
+		}
+		return keep, &Hyphenation{Split: hyphenated, Result: result, Reason: "both words known"}
+	}
+}
+
+// splitWordEnd returns the first part of a word split with a hyphen
+// at the end of the line, or "".
+func splitWordEnd(line string) string {
+	if !strings.HasSuffix(line, "-") || strings.HasSuffix(line, "--") {
+		return ""
+	}
+	line = strings.TrimSuffix(line, "-")
+	i := strings.LastIndexFunc(line, func(r rune) bool { return !unicode.IsLetter(r) })
+	return line[i+1:]
+}
+
+// splitNumberEnd tells whether the line ends with a number and a
+// hyphen, like the start of "12-14".
+func splitNumberEnd(line string) bool {
+	line, ok := strings.CutSuffix(line, "-")
+	r, _ := utf8.DecodeLastRuneInString(line)
+	return ok && unicode.IsDigit(r)
+}
+
+func isConjunction(lang, word string) bool {
+	for _, c := range conjunctions[lang] {
+		if strings.EqualFold(c, word) {
+			return true
+		}
+	}
+	return false
+}
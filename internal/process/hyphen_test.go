@@ -0,0 +1,72 @@
+package process
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDehyphenate(t *testing.T) {
+	pb := PageBreak("0005", "5")
+	tests := []struct {
+		name      string
+		lang      string
+		seen      string // Text elsewhere in the book
+		text      string
+		want      string
+		ambiguous []string // Results of the ambiguous cases
+	}{
+		{"unknown word is joined", "sv", "",
+			"en kaffe-\nkopp till", "en kaffekopp\ntill", []string{"kaffekopp"}},
+		{"joined word listed", "sv", "",
+			"den för-\nsta gången", "den första\ngången", nil},
+		{"list goes before text", "sv", "för-sta för-sta",
+			"den för-\nsta gången", "den första\ngången", nil},
+		{"joined word listed in other language", "fi", "",
+			"ensim-\nmäinen kerta", "ensimmäinen\nkerta", nil},
+		{"joined word seen", "sv", "en kaffekopp",
+			"en kaffe-\nkopp till", "en kaffekopp\ntill", nil},
+		{"hyphenated word seen", "sv", "lite rock-musik",
+			"mycket rock-\nmusik nu", "mycket rock-musik\nnu", nil},
+		{"hyphenated word more common", "sv", "rock-musik rock-musik rockmusik",
+			"mycket rock-\nmusik nu", "mycket rock-musik\nnu", []string{"rock-musik"}},
+		{"joined word more common", "sv", "rock-musik rockmusik rockmusik",
+			"mycket rock-\nmusik nu", "mycket rockmusik\nnu", []string{"rockmusik"}},
+		{"uppercase rest", "sv", "",
+			"till Nord-\nAmerika nu", "till Nord-Amerika\nnu", nil},
+		{"all uppercase first part", "sv", "",
+			"ett STOR-\nSLAM nu", "ett STORSLAM\nnu", []string{"STORSLAM"}},
+		{"whole line moves up", "sv", "",
+			"den för-\nsta\ngången", "den första\ngången", nil},
+		{"over page break", "sv", "",
+			"den för-\n" + pb + "\nsta gången", "den första\n" + pb + "\ngången", nil},
+		{"conjunction", "sv", "",
+			"syster-\noch brödraskap", "syster-\noch brödraskap", nil},
+		{"conjunction in other language", "en", "",
+			"brother-\nand sisterhood", "brother-\nand sisterhood", nil},
+		{"not a conjunction in language", "en", "",
+			"syster-\noch", "systeroch", []string{"systeroch"}},
+		{"dash", "sv", "",
+			"han sade --\noch gick", "han sade --\noch gick", nil},
+		{"number range", "sv", "",
+			"sid. 12-\n14 där", "sid. 12-14\ndär", nil},
+		{"rest not a word", "sv", "",
+			"ett ja-\n»nej« nu", "ett ja-\n»nej« nu", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVocabulary(tt.lang)
+			v.Add(tt.seen)
+			got, hs := Dehyphenate(tt.text, v)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			var ambiguous []string
+			for _, h := range hs {
+				ambiguous = append(ambiguous, h.Result)
+			}
+			if !slices.Equal(ambiguous, tt.ambiguous) {
+				t.Errorf("ambiguous %q, want %q", ambiguous, tt.ambiguous)
+			}
+		})
+	}
+}
@@ -14,11 +14,32 @@ import (
 )
 
 // RunebergTxt tries to add (non-closed) <p> at the right places in a
-// Runeberg txt-file.
+// Runeberg txt-file, reflowing the lines of each paragraph (see
+// reflow). Paragraphs of lines broken on purpose get class="verse".
 func RunebergTxt(body string) (string, error) {
 	var out string
 	betweenParagraphs := false
 	sawChapterTag := false
+	inTable := false
+
+	typical := typicalLineLength(body)
+	var para []string // Lines of the current paragraph
+	wrapPara := false // Whether to put it in a <p>
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		text, verse := reflow(para, typical)
+		if wrapPara {
+			if verse {
+				out += "\n<p class=\"verse\">"
+			} else {
+				out += "\n<p>"
+			}
+		}
+		out += text + "\n"
+		para = nil
+	}
 
 	// Note that we're relying on chapter and table (closing) tags
 	// sitting at the beginning of a line.
@@ -26,10 +47,19 @@ func RunebergTxt(body string) (string, error) {
 	for scanner.Scan() {
 		line := scanner.Text()
 
+		if inTable {
+			out += line + "\n"
+			if strings.HasPrefix(line, "</table") {
+				inTable = false
+			}
+			continue
+		}
+
 		if strings.HasPrefix(line, "<chapter") {
 			if sawChapterTag {
 				return "", fmt.Errorf("already got chapter tag")
 			}
+			flush()
 			sawChapterTag = true
 			continue
 		}
@@ -46,22 +76,21 @@ func RunebergTxt(body string) (string, error) {
 			sawChapterTag = false
 			// Don't introduce a new paragraph if we just dealt with <chapter>
 			betweenParagraphs = false
+			out += line + "\n"
+			continue
 		}
 		if strings.HasPrefix(line, "</chapter") {
 			continue
 		}
 
 		if line == "" {
+			flush()
 			betweenParagraphs = true
 			continue
 		}
 
-		// <table> must not be inside a <p>
-		if betweenParagraphs && !strings.HasPrefix(line, "<table") {
-			out += "\n<p>"
-		}
-
 		if strings.HasPrefix(line, "<table") {
+			flush()
 			// We close the previous <p>aragraph here, otherwise Go's
 			// Parser+Render ends up putting the table tag inside the
 			// previous p tag, which is not permitted since they are
@@ -69,16 +98,23 @@ func RunebergTxt(body string) (string, error) {
 			if betweenParagraphs {
 				out += "</p>\n"
 			}
-			out += "\n"
+			out += "\n" + line + "\n"
+			inTable = !strings.Contains(line, "</table")
+			betweenParagraphs = false
+			continue
 		}
 
+		if len(para) == 0 {
+			wrapPara = betweenParagraphs
+		}
 		betweenParagraphs = false
 
-		out += line + "\n"
+		para = append(para, line)
 	}
 	if err := scanner.Err(); err != nil {
 		return "", fmt.Errorf("Scan failed: %w", err)
 	}
+	flush()
 
 	return out, nil
 }
@@ -114,8 +150,8 @@ func RunebergHtml(body string) (string, error) {
 	re := regexp.MustCompile(`([^\s>’»])(<[a-z0-9_ ="]+>)`)
 	body = re.ReplaceAllString(body, "${1} ${2}")
 
-	// Ensure newline before hard linebreak
-	re = regexp.MustCompile(`(.+)(<br/>)`)
+	// Ensure newline after hard linebreak
+	re = regexp.MustCompile(`(<br/>)([^\n])`)
 	body = re.ReplaceAllString(body, "${1}\n${2}")
 
 	if strings.Contains(body, `=""`) {
@@ -125,6 +161,19 @@ func RunebergHtml(body string) (string, error) {
 	return body, nil
 }
 
+// PageBreak returns a line marking where a page of the printed book
+// begins, which the processing keeps in place (Dehyphenate joins words
+// over it). The page is the name of the page file, like 0005, and the
+// label the printed page number.
+func PageBreak(page, label string) string {
+	return fmt.Sprintf(`<span class="pagebreak" id="page-%s" title="%s"></span>`,
+		html.EscapeString(page), html.EscapeString(label))
+}
+
+func isPageBreak(line string) bool {
+	return strings.HasPrefix(line, `<span class="pagebreak"`)
+}
+
 func preprocessRunebergHtml(s string) string {
 	s = strings.ReplaceAll(s, "<sp>", `<span class="spaced">`)
 	s = strings.ReplaceAll(s, "</sp>", "</span>")
@@ -135,8 +184,8 @@ func preprocessRunebergHtml(s string) string {
 	s = strings.ReplaceAll(s, "<big>", `<span class="big">`)
 	s = strings.ReplaceAll(s, "</big>", "</span>")
 
-	s = strings.ReplaceAll(s, "<footnote>", `<span class="footnote"> [fotnot: `)
-	s = strings.ReplaceAll(s, "</footnote>", "]</span>")
+	s = strings.ReplaceAll(s, "<footnote>", `<span class="footnote">`)
+	s = strings.ReplaceAll(s, "</footnote>", "</span>")
 
 	// Apparently XHTML and thus EPUB doesn't have (many) named entities
 	// ensp 8194 0x2002 (approx 2 spaces)
@@ -0,0 +1,98 @@
+package process
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var tagRE = regexp.MustCompile(`<[^>]*>`)
+
+// textLength is the length of a line in runes, not counting tags.
+func textLength(line string) int {
+	return utf8.RuneCountInString(strings.TrimSpace(tagRE.ReplaceAllString(line, "")))
+}
+
+// typicalLineLength is the median length of the lines of text (not
+// tags) in the body, the width of the printed page.
+func typicalLineLength(body string) int {
+	var lengths []int
+	for _, line := range strings.Split(body, "\n") {
+		if line == "" || strings.HasPrefix(line, "<") {
+			continue
+		}
+		lengths = append(lengths, textLength(line))
+	}
+	if len(lengths) == 0 {
+		return 0
+	}
+	slices.Sort(lengths)
+	return lengths[len(lengths)/2]
+}
+
+// Lines shorter than this part of the typical line length were broken
+// on purpose
+const shortLine = 0.7
+
+// Lines this part of the typical line length or longer fill the
+// width of the page, as lines of prose do
+const fullLine = 0.9
+
+// reflow joins the hard-wrapped lines of a paragraph with spaces,
+// normalizing the whitespace. Unless the lines seem to be broken on
+// purpose, as in verse, addresses or signatures; then they are joined
+// with <br/>, and the returned bool is true. That is when a line
+// other than the last is much shorter than typical, or when there
+// are 3 or more lines that all begin with an uppercase letter and
+// none but the last fills the page (capitals alone are common in
+// prose, as in German).
+func reflow(lines []string, typical int) (string, bool) {
+	// Page breaks go with the line after them (or before, if last)
+	var joined []string
+	var pageBreaks string
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if isPageBreak(line) {
+			pageBreaks += line
+			continue
+		}
+		joined = append(joined, pageBreaks+line)
+		pageBreaks = ""
+	}
+	if pageBreaks != "" {
+		if len(joined) == 0 {
+			return pageBreaks, false
+		}
+		joined[len(joined)-1] += pageBreaks
+	}
+	lines = joined
+
+	if len(lines) < 2 {
+		return strings.Join(lines, ""), false
+	}
+
+	short, full := false, false
+	for _, line := range lines[:len(lines)-1] {
+		length := float64(textLength(line))
+		short = short || length < shortLine*float64(typical)
+		full = full || length >= fullLine*float64(typical)
+	}
+	uppercase := len(lines) >= 3 && !full
+	for _, line := range lines {
+		text := tagRE.ReplaceAllString(line, "")
+		if i := strings.IndexFunc(text, unicode.IsLetter); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(text[i:])
+			uppercase = uppercase && unicode.IsUpper(r)
+		}
+	}
+	if short || uppercase {
+		return strings.Join(lines, "<br/>\n"), true
+	}
+
+	// Dehyphenate has already moved the rest of split words up, so a
+	// hyphen still at a line end is kept on purpose, as in "syster-
+	// och brödraskap"
+	return strings.Join(lines, " "), false
+}
@@ -0,0 +1,105 @@
+package process
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReflowDehyphenated(t *testing.T) {
+	pb := PageBreak("0005", "5")
+	tests := []struct {
+		name string
+		lang string
+		text string
+		want string
+	}{
+		{"split word", "sv",
+			"han kom för-\nsta gången", "han kom första gången"},
+		{"conjunction", "sv",
+			"om syster-\noch brödraskap", "om syster- och brödraskap"},
+		{"conjunction and split word", "sv",
+			"om syster-\noch brödra-\nskap nu", "om syster- och brödraskap nu"},
+		{"conjunction over page break", "sv",
+			"om syster-\n" + pb + "\noch brödraskap", "om syster- " + pb + "och brödraskap"},
+		{"split word over page break", "sv",
+			"han kom för-\n" + pb + "\nsta gången", "han kom första " + pb + "gången"},
+		{"conjunction in other language", "en",
+			"his brother-\nand sisterhood", "his brother- and sisterhood"},
+		{"dash", "sv",
+			"han sade --\noch gick", "han sade -- och gick"},
+		{"number range", "sv",
+			"sid. 12-\n14 där", "sid. 12-14 där"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, _ := Dehyphenate(tt.text, NewVocabulary(tt.lang))
+			got, verse := reflow(strings.Split(text, "\n"), 0)
+			if got != tt.want || verse {
+				t.Errorf("got %q (verse %t), want %q", got, verse, tt.want)
+			}
+		})
+	}
+}
+
+func TestReflowVerse(t *testing.T) {
+	const typical = 42
+	tests := []struct {
+		name  string
+		lines []string
+		verse bool
+	}{
+		{"prose", []string{
+			"Det var en gång en gammal man som bodde",
+			"i en liten stuga vid skogen, och han hade",
+			"inga barn.",
+		}, false},
+		{"prose with capitals", []string{
+			"Der Mann ging mit seinem Hund in den Wald",
+			"Und der Hund lief vor ihm her bis zum Bach",
+			"Und dort blieben sie.",
+		}, false},
+		{"prose lines beginning with names", []string{
+			"Karl och Anna gick hem genom skogen, där de",
+			"Erik mötte dem vid grinden och sade att",
+			"Maria redan väntade.",
+		}, false},
+		{"verse", []string{
+			"Där björkarna susa i vårens tid,",
+			"Där trastarna sjunga sin sång,",
+			"Där vill jag bo, där vill jag dö,",
+			"Där står min vagga vid älvens brus.",
+		}, true},
+		{"short line", []string{
+			"Stockholm den 3 maj 1890.",
+			"Käre vän, tack för ditt brev som kom i går",
+		}, true},
+		{"two lines with capitals", []string{
+			"Där björkarna susa i vårens tid,",
+			"Där trastarna sjunga sin sång,",
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, verse := reflow(tt.lines, typical)
+			want := strings.Join(tt.lines, " ")
+			if tt.verse {
+				want = strings.Join(tt.lines, "<br/>\n")
+			}
+			if got != want || verse != tt.verse {
+				t.Errorf("got %q (verse %t), want %q", got, verse, want)
+			}
+		})
+	}
+}
+
+func TestRunebergTxtKeepsConjunctionHyphen(t *testing.T) {
+	text, _ := Dehyphenate("han talade om syster-\noch brödraskap och för-\nsta gången.\n\nNästa stycke.\n", NewVocabulary("sv"))
+	got, err := RunebergTxt(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "han talade om syster- och brödraskap och första gången.\n\n<p>Nästa stycke.\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}